@@ -0,0 +1,74 @@
+// Package analysis implements static analysis of EBNF grammars.
+package analysis
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// IsLexical reports whether the given production name denotes a lexical
+// production (i.e. the name does not start with an uppercase letter).
+func IsLexical(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return !unicode.IsUpper(r)
+}
+
+// Names returns the production names of the given grammar in sorted order.
+func Names(grammar ebnf.Grammar) []string {
+	var names []string
+	for name := range grammar {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FirstSyntactic returns the name of the first syntactic production of the
+// grammar by minimum source offset, or the empty string if the grammar has no
+// syntactic productions.
+func FirstSyntactic(grammar ebnf.Grammar) string {
+	var first string
+	min := -1
+	for name, prod := range grammar {
+		if IsLexical(name) {
+			continue
+		}
+		if off := prod.Pos().Offset; min == -1 || off < min {
+			first = name
+			min = off
+		}
+	}
+	return first
+}
+
+// ExprDepth returns the nesting depth of the given expression.
+func ExprDepth(x ebnf.Expression) int {
+	switch x := x.(type) {
+	case nil:
+		return 0
+	case ebnf.Alternative:
+		depth := 0
+		for _, e := range x {
+			depth = max(depth, ExprDepth(e))
+		}
+		return 1 + depth
+	case ebnf.Sequence:
+		depth := 0
+		for _, e := range x {
+			depth = max(depth, ExprDepth(e))
+		}
+		return 1 + depth
+	case *ebnf.Group:
+		return 1 + ExprDepth(x.Body)
+	case *ebnf.Option:
+		return 1 + ExprDepth(x.Body)
+	case *ebnf.Repetition:
+		return 1 + ExprDepth(x.Body)
+	default:
+		// *ebnf.Name, *ebnf.Token, *ebnf.Range and *ebnf.Bad.
+		return 1
+	}
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"sort"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// DetectAllCycles returns the cycles of the production graph of the grammar;
+// i.e. the graph with an edge from A to B if B is referenced in the expression
+// of A, regardless of position. Each cycle is reported as the list of
+// production names along the cycle, starting with the least name.
+//
+// The cycles are ordered topologically by strongly connected component, such
+// that cycles of referencing productions precede cycles of referenced
+// productions. The cycles of a strongly connected component are ordered by
+// discovery.
+//
+// The cycles are enumerated using Johnson's algorithm. Note that the number of
+// cycles may be exponential in the size of the grammar.
+func DetectAllCycles(grammar ebnf.Grammar) [][]string {
+	g := make(map[string][]string)
+	for _, name := range Names(grammar) {
+		var refs []string
+		walkNames(grammar[name].Expr, "", func(x *ebnf.Name, path string) {
+			if _, ok := grammar[x.String]; ok && !contains(refs, x.String) {
+				refs = append(refs, x.String)
+			}
+		})
+		sort.Strings(refs)
+		g[name] = refs
+	}
+	// Enumerate cycles of each strongly connected component, in topological
+	// order.
+	var cycles [][]string
+	for _, scc := range topologicalSCCs(g) {
+		cycles = append(cycles, johnson(g, scc)...)
+	}
+	return cycles
+}
+
+// topologicalSCCs returns the strongly connected components of the given
+// directed graph in topological order of the condensation graph. Ties are
+// broken by the first node of the components.
+func topologicalSCCs(g map[string][]string) [][]string {
+	sccs := stronglyConnected(g)
+	comp := make(map[string]int)
+	for i, scc := range sccs {
+		for _, v := range scc {
+			comp[v] = i
+		}
+	}
+	// Kahn's algorithm on the condensation graph.
+	indegree := make([]int, len(sccs))
+	succs := make([][]int, len(sccs))
+	for v, ws := range g {
+		for _, w := range ws {
+			if comp[v] != comp[w] {
+				succs[comp[v]] = append(succs[comp[v]], comp[w])
+				indegree[comp[w]]++
+			}
+		}
+	}
+	var ready []int
+	for i := range sccs {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	var sorted [][]string
+	for len(ready) > 0 {
+		// sccs are sorted by first node; pick the least ready component.
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, sccs[i])
+		for _, j := range succs[i] {
+			indegree[j]--
+			if indegree[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+	return sorted
+}
+
+// johnson returns the cycles of the given directed graph within the strongly
+// connected component, using Johnson's algorithm.
+func johnson(g map[string][]string, scc []string) [][]string {
+	var cycles [][]string
+	// scc is sorted by name; cycles are enumerated from their least node s,
+	// restricted to nodes not less than s.
+	for i, s := range scc {
+		allowed := make(map[string]bool)
+		for _, v := range scc[i:] {
+			allowed[v] = true
+		}
+		c := &circuits{
+			g:       g,
+			s:       s,
+			allowed: allowed,
+			blocked: make(map[string]bool),
+			b:       make(map[string]map[string]bool),
+		}
+		c.circuit(s)
+		cycles = append(cycles, c.cycles...)
+	}
+	return cycles
+}
+
+// circuits tracks the state of Johnson's algorithm for cycles through a given
+// start node.
+type circuits struct {
+	// Directed graph.
+	g map[string][]string
+	// Start node; the least node of the cycles.
+	s string
+	// Nodes allowed on the cycles.
+	allowed map[string]bool
+	// Blocked nodes.
+	blocked map[string]bool
+	// Blocked predecessors of each node, unblocked when the node is unblocked.
+	b map[string]map[string]bool
+	// Path from the start node.
+	stack []string
+	// Cycles found.
+	cycles [][]string
+}
+
+// circuit searches for cycles through the start node along paths from v, and
+// reports whether a cycle was found.
+func (c *circuits) circuit(v string) bool {
+	found := false
+	c.stack = append(c.stack, v)
+	c.blocked[v] = true
+	for _, w := range c.g[v] {
+		if !c.allowed[w] {
+			continue
+		}
+		if w == c.s {
+			cycle := make([]string, len(c.stack))
+			copy(cycle, c.stack)
+			c.cycles = append(c.cycles, cycle)
+			found = true
+		} else if !c.blocked[w] && c.circuit(w) {
+			found = true
+		}
+	}
+	if found {
+		c.unblock(v)
+	} else {
+		for _, w := range c.g[v] {
+			if !c.allowed[w] {
+				continue
+			}
+			if c.b[w] == nil {
+				c.b[w] = make(map[string]bool)
+			}
+			c.b[w][v] = true
+		}
+	}
+	c.stack = c.stack[:len(c.stack)-1]
+	return found
+}
+
+// unblock unblocks the given node and its blocked predecessors.
+func (c *circuits) unblock(u string) {
+	c.blocked[u] = false
+	for w := range c.b[u] {
+		delete(c.b[u], w)
+		if c.blocked[w] {
+			c.unblock(w)
+		}
+	}
+}
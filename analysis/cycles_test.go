@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/ebnf"
+)
+
+func TestDetectAllCycles(t *testing.T) {
+	golden := []struct {
+		grammar string
+		// Expected cycles, in order.
+		want [][]string
+	}{
+		// acyclic grammar.
+		{
+			grammar: `A = B C . B = C | "b" . C = "c" .`,
+			want:    nil,
+		},
+		// self-loop.
+		{
+			grammar: `A = "(" A ")" | "x" .`,
+			want:    [][]string{{"A"}},
+		},
+		// two overlapping cycles in one strongly connected component.
+		{
+			grammar: `A = B . B = A | C . C = A .`,
+			want:    [][]string{{"A", "B"}, {"A", "B", "C"}},
+		},
+		// cycles of referencing productions precede those of referenced
+		// productions.
+		{
+			grammar: `Y = "y" [ Y ] . Z = Z Y | "z" .`,
+			want:    [][]string{{"Z"}, {"Y"}},
+		},
+		// self-loop within a larger cycle; references are not repeated.
+		{
+			grammar: `A = B B | A . B = A "b" .`,
+			want:    [][]string{{"A"}, {"A", "B"}},
+		},
+	}
+	for _, g := range golden {
+		grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(g.grammar))
+		if err != nil {
+			t.Fatalf("unable to parse grammar %q; %v", g.grammar, err)
+		}
+		want := fmt.Sprint(g.want)
+		// cycles are ordered deterministically, regardless of map iteration
+		// order.
+		for i := 0; i < 10; i++ {
+			if got := fmt.Sprint(DetectAllCycles(grammar)); got != want {
+				t.Errorf("%q: cycles mismatch; expected %s, got %s", g.grammar, want, got)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package analysis
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/format"
+	"golang.org/x/exp/ebnf"
+)
+
+// Diagnostic is a dead or possibly dead alternative of a production.
+type Diagnostic struct {
+	// Name of the enclosing production.
+	Production string
+	// Path to the alternative expression within the expression of the enclosing
+	// production; e.g. "Seq[1].Group". The path is empty if the expression is
+	// the alternative expression itself.
+	ExprPath string
+	// Index of the dead alternative within the alternative expression.
+	Index int
+	// Dead alternative.
+	Alt ebnf.Expression
+	// Reports whether the alternative is provably dead; otherwise, the
+	// alternative is possibly dead, based on FIRST sets.
+	Proven bool
+}
+
+// String returns the string representation of the diagnostic.
+func (d Diagnostic) String() string {
+	path := d.Production
+	if len(d.ExprPath) > 0 {
+		path += "." + d.ExprPath
+	}
+	if !d.Proven {
+		return fmt.Sprintf("%v: possibly dead alternative %s.Alt[%d]", d.Alt.Pos(), path, d.Index)
+	}
+	return fmt.Sprintf("%v: dead alternative %s.Alt[%d]", d.Alt.Pos(), path, d.Index)
+}
+
+// RemoveDeadAlternatives returns a copy of the grammar with provably dead
+// alternatives removed, and the diagnostics of dead and possibly dead
+// alternatives, ordered by production name and position within its expression.
+//
+// Alternatives are tried in order, as with the ordered choice of the runtime
+// evaluator of the speak tool; the first matching alternative is used. An
+// alternative B is provably dead after an earlier alternative A of the same
+// alternative expression if A is identical to B or to a prefix of the sequence
+// of B (e.g. "a" | "a" "b"), as A then matches whenever B matches.
+//
+// An alternative B is possibly dead after an earlier alternative A if FIRST(A)
+// covers FIRST(B) and neither A nor B is nullable; i.e. any input B may begin
+// with is also claimed by A. This is only a heuristic, as B is still tried
+// when A fails to match; e.g. given "@" name | "@" digits, the input "@123" is
+// matched by the second alternative. Possibly dead alternatives are reported
+// but not removed. Tokens are not considered covered by character ranges other
+// than for single characters, so keywords are not reported after identifiers.
+func RemoveDeadAlternatives(grammar ebnf.Grammar) (ebnf.Grammar, []Diagnostic) {
+	r := &remover{
+		first:    First(grammar),
+		nullable: Nullable(grammar),
+	}
+	g := make(ebnf.Grammar)
+	for _, name := range Names(grammar) {
+		prod := grammar[name]
+		r.prod = name
+		g[name] = &ebnf.Production{
+			Name: clone(prod.Name).(*ebnf.Name),
+			Expr: r.expr(prod.Expr, ""),
+		}
+	}
+	return g, r.diags
+}
+
+// remover tracks the state of dead alternative removal.
+type remover struct {
+	// FIRST sets of the grammar.
+	first map[string]Set
+	// Nullable productions of the grammar.
+	nullable map[string]bool
+	// Name of the production being processed.
+	prod string
+	// Diagnostics of dead alternatives.
+	diags []Diagnostic
+}
+
+// expr returns a copy of the given expression, located at the given path, with
+// dead alternatives removed.
+func (r *remover) expr(x ebnf.Expression, path string) ebnf.Expression {
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case ebnf.Alternative:
+		var firsts []Set
+		for _, e := range x {
+			firsts = append(firsts, FirstExpr(e, r.first, r.nullable))
+		}
+		var alt ebnf.Alternative
+		for i, e := range x {
+			proven := isShadowed(x, i)
+			if proven || r.isPossiblyDead(x, firsts, i) {
+				d := Diagnostic{
+					Production: r.prod,
+					ExprPath:   path,
+					Index:      i,
+					Alt:        e,
+					Proven:     proven,
+				}
+				r.diags = append(r.diags, d)
+				if proven {
+					continue
+				}
+			}
+			alt = append(alt, r.expr(e, joinPath(path, fmt.Sprintf("Alt[%d]", i))))
+		}
+		if len(alt) == 1 {
+			return alt[0]
+		}
+		return alt
+	case ebnf.Sequence:
+		seq := make(ebnf.Sequence, len(x))
+		for i, e := range x {
+			seq[i] = r.expr(e, joinPath(path, fmt.Sprintf("Seq[%d]", i)))
+		}
+		return seq
+	case *ebnf.Name, *ebnf.Token, *ebnf.Range, *ebnf.Bad:
+		return clone(x)
+	case *ebnf.Group:
+		return &ebnf.Group{Lparen: x.Lparen, Body: r.expr(x.Body, joinPath(path, "Group"))}
+	case *ebnf.Option:
+		return &ebnf.Option{Lbrack: x.Lbrack, Body: r.expr(x.Body, joinPath(path, "Opt"))}
+	case *ebnf.Repetition:
+		return &ebnf.Repetition{Lbrace: x.Lbrace, Body: r.expr(x.Body, joinPath(path, "Rep"))}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// isShadowed reports whether the i:th alternative of the given alternative
+// expression is provably dead; i.e. an earlier alternative is identical to it or
+// to a prefix of its sequence.
+func isShadowed(x ebnf.Alternative, i int) bool {
+	elems := seqElems(x[i])
+	for j := 0; j < i; j++ {
+		prefix := seqElems(x[j])
+		if len(prefix) == 0 || len(prefix) > len(elems) {
+			continue
+		}
+		shadowed := true
+		for k, e := range prefix {
+			if format.SprintExpr(e) != format.SprintExpr(elems[k]) {
+				shadowed = false
+				break
+			}
+		}
+		if shadowed {
+			return true
+		}
+	}
+	return false
+}
+
+// seqElems returns the elements of the given expression as a sequence;
+// grouped expressions are unwrapped.
+func seqElems(x ebnf.Expression) []ebnf.Expression {
+	for {
+		g, ok := x.(*ebnf.Group)
+		if !ok {
+			break
+		}
+		x = g.Body
+	}
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case ebnf.Sequence:
+		return x
+	default:
+		return []ebnf.Expression{x}
+	}
+}
+
+// isPossiblyDead reports whether the i:th alternative of the given alternative
+// expression is possibly dead, based on the FIRST sets of the alternatives.
+func (r *remover) isPossiblyDead(x ebnf.Alternative, firsts []Set, i int) bool {
+	if IsNullable(x[i], r.nullable) || len(firsts[i]) == 0 {
+		return false
+	}
+	for j := 0; j < i; j++ {
+		if !IsNullable(x[j], r.nullable) && covers(firsts[j], firsts[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether every terminal of s2 is covered by a terminal of s.
+func covers(s, s2 Set) bool {
+	for t := range s2 {
+		covered := false
+		for u := range s {
+			if u.covers(t) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// covers reports whether the terminal covers the terminal t; i.e. t is the
+// same terminal, or a character range or single character token within the
+// character range of the terminal.
+func (u Terminal) covers(t Terminal) bool {
+	switch {
+	case u == t:
+		return true
+	case len(u.Token) > 0, u == EOF, t == EOF:
+		return false
+	case len(t.Token) > 0:
+		r, size := utf8.DecodeRuneInString(t.Token)
+		return size == len(t.Token) && u.Begin <= r && r <= u.End
+	default:
+		return u.Begin <= t.Begin && t.End <= u.End
+	}
+}
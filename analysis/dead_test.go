@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mewmew/speak/format"
+	"golang.org/x/exp/ebnf"
+)
+
+func TestRemoveDeadAlternatives(t *testing.T) {
+	golden := []struct {
+		grammar string
+		// Expected production A after removal of dead alternatives.
+		want string
+		// Expected diagnostics.
+		diags []string
+	}{
+		// identical alternatives.
+		{
+			grammar: `A = "a" | "b" | "a" .`,
+			want:    `A = "a" | "b" .`,
+			diags:   []string{`test.ebnf:1:17: dead alternative A.Alt[2]`},
+		},
+		// prefix of sequence.
+		{
+			grammar: `A = "a" | "a" "b" .`,
+			want:    `A = "a" .`,
+			diags:   []string{`test.ebnf:1:11: dead alternative A.Alt[1]`},
+		},
+		// covered FIRST set is only possibly dead, as ordered choice backtracks.
+		{
+			grammar: `A = "@" name | "@" digits . name = "a" … "z" . digits = "0" … "9" .`,
+			want:    `A = "@" name | "@" digits .`,
+			diags:   []string{`test.ebnf:1:16: possibly dead alternative A.Alt[1]`},
+		},
+		// nullable alternatives are never dead.
+		{
+			grammar: `A = "a" | [ "a" ] .`,
+			want:    `A = "a" | [ "a" ] .`,
+		},
+	}
+	for _, g := range golden {
+		grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(g.grammar))
+		if err != nil {
+			t.Fatalf("unable to parse grammar %q; %v", g.grammar, err)
+		}
+		got, diags := RemoveDeadAlternatives(grammar)
+		if s := format.SprintProd(got["A"]); s != g.want {
+			t.Errorf("%q: production mismatch; expected %q, got %q", g.grammar, g.want, s)
+		}
+		if len(diags) != len(g.diags) {
+			t.Errorf("%q: number of diagnostics mismatch; expected %d, got %d (%v)", g.grammar, len(g.diags), len(diags), diags)
+			continue
+		}
+		for i, d := range diags {
+			if s := d.String(); s != g.diags[i] {
+				t.Errorf("%q: diagnostic mismatch; expected %q, got %q", g.grammar, g.diags[i], s)
+			}
+		}
+	}
+}
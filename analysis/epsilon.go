@@ -0,0 +1,248 @@
+package analysis
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// EliminateEpsilon returns a copy of the grammar rewritten to not match the
+// empty string, other than through the root productions (those not referenced
+// by other productions), preserving the language of the root productions.
+//
+// The body of each production is rewritten to match its language without the
+// empty string, and the empty string is reintroduced at use sites by making
+// references to nullable productions optional. Empty alternatives are thereby
+// removed, and e.g. given that B and C are nullable
+//
+//	A = B C .
+//
+// is rewritten to
+//
+//	A = [ B [ C ] | C ] .
+//
+// where the outermost option is kept as A is a root production. Productions
+// only matching the empty string are removed, unless they are root productions.
+func EliminateEpsilon(grammar ebnf.Grammar) ebnf.Grammar {
+	e := &eliminator{
+		nullable:    Nullable(grammar),
+		nonEmptyMap: NonEmpty(grammar),
+	}
+	referenced := make(map[string]bool)
+	for name, prod := range grammar {
+		walkNames(prod.Expr, "", func(x *ebnf.Name, path string) {
+			if x.String != name {
+				referenced[x.String] = true
+			}
+		})
+	}
+	g := make(ebnf.Grammar)
+	for _, name := range Names(grammar) {
+		prod := grammar[name]
+		root := !referenced[name]
+		if !e.nonEmptyMap[name] && !root {
+			// production only matching the empty string.
+			continue
+		}
+		body := e.nonEmpty(prod.Expr)
+		if e.nullable[name] && root && body != nil {
+			body = &ebnf.Option{Lbrack: prod.Pos(), Body: body}
+		}
+		g[name] = &ebnf.Production{
+			Name: clone(prod.Name).(*ebnf.Name),
+			Expr: body,
+		}
+	}
+	return g
+}
+
+// NonEmpty returns the set of productions of the grammar which match at least
+// one non-empty string.
+func NonEmpty(grammar ebnf.Grammar) map[string]bool {
+	nonEmpty := make(map[string]bool)
+	// Iterate until a fixed point is reached.
+	for changed := true; changed; {
+		changed = false
+		for name, prod := range grammar {
+			if nonEmpty[name] {
+				continue
+			}
+			if isNonEmpty(prod.Expr, nonEmpty) {
+				nonEmpty[name] = true
+				changed = true
+			}
+		}
+	}
+	return nonEmpty
+}
+
+// isNonEmpty reports whether the given expression matches at least one
+// non-empty string, based on the given set of non-empty productions.
+func isNonEmpty(x ebnf.Expression, nonEmpty map[string]bool) bool {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+		return false
+	case ebnf.Alternative:
+		for _, e := range x {
+			if isNonEmpty(e, nonEmpty) {
+				return true
+			}
+		}
+		return false
+	case ebnf.Sequence:
+		for _, e := range x {
+			if isNonEmpty(e, nonEmpty) {
+				return true
+			}
+		}
+		return false
+	case *ebnf.Name:
+		return nonEmpty[x.String]
+	case *ebnf.Token:
+		return len(x.String) > 0
+	case *ebnf.Range:
+		return true
+	case *ebnf.Group:
+		return isNonEmpty(x.Body, nonEmpty)
+	case *ebnf.Option:
+		return isNonEmpty(x.Body, nonEmpty)
+	case *ebnf.Repetition:
+		return isNonEmpty(x.Body, nonEmpty)
+	case *ebnf.Bad:
+		return false
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// eliminator tracks the state of epsilon elimination.
+type eliminator struct {
+	// Nullable productions of the grammar.
+	nullable map[string]bool
+	// Productions of the grammar matching at least one non-empty string.
+	nonEmptyMap map[string]bool
+}
+
+// nonEmpty returns an expression matching the language of the given expression
+// without the empty string, or nil if the expression only matches the empty
+// string. Names of nullable productions are assumed to no longer match the
+// empty string.
+func (e *eliminator) nonEmpty(x ebnf.Expression) ebnf.Expression {
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case ebnf.Alternative:
+		var alt ebnf.Alternative
+		for _, a := range x {
+			switch body := e.nonEmpty(a).(type) {
+			case nil:
+				// alternative only matching the empty string.
+			case ebnf.Alternative:
+				alt = append(alt, body...)
+			default:
+				alt = append(alt, body)
+			}
+		}
+		return alternative(alt)
+	case ebnf.Sequence:
+		// The first non-empty element is at index i, preceded by nullable
+		// elements which match the empty string, and followed by arbitrary
+		// elements.
+		//
+		//    x y z   =>   x' y* z* | y' z* | z'   (for nullable x and y)
+		var alt ebnf.Alternative
+		for i, a := range x {
+			if first := e.nonEmpty(a); first != nil {
+				seq := appendSeq(nil, first)
+				for _, b := range x[i+1:] {
+					if rest := e.full(b); rest != nil {
+						seq = appendSeq(seq, rest)
+					}
+				}
+				alt = append(alt, sequence(seq))
+			}
+			if !IsNullable(a, e.nullable) {
+				break
+			}
+		}
+		return alternative(alt)
+	case *ebnf.Name:
+		if !e.nonEmptyMap[x.String] {
+			return nil
+		}
+		return clone(x)
+	case *ebnf.Token:
+		if len(x.String) == 0 {
+			return nil
+		}
+		return clone(x)
+	case *ebnf.Range, *ebnf.Bad:
+		return clone(x)
+	case *ebnf.Group:
+		return e.nonEmpty(x.Body)
+	case *ebnf.Option:
+		return e.nonEmpty(x.Body)
+	case *ebnf.Repetition:
+		// { x }   =>   x' { x' }
+		body := e.nonEmpty(x.Body)
+		if body == nil {
+			return nil
+		}
+		return appendSeq(appendSeq(nil, body), &ebnf.Repetition{Lbrace: x.Lbrace, Body: clone(body)})
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// full returns an expression matching the language of the given expression,
+// or nil if the expression only matches the empty string. Names of nullable
+// productions are assumed to no longer match the empty string.
+func (e *eliminator) full(x ebnf.Expression) ebnf.Expression {
+	body := e.nonEmpty(x)
+	if body == nil || !IsNullable(x, e.nullable) {
+		return body
+	}
+	if rep, ok := x.(*ebnf.Repetition); ok {
+		// { x }   =>   { x' }
+		return &ebnf.Repetition{Lbrace: rep.Lbrace, Body: e.nonEmpty(rep.Body)}
+	}
+	return &ebnf.Option{Lbrack: x.Pos(), Body: body}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// alternative returns the given alternatives as an expression; nil if empty,
+// and the sole alternative if only one.
+func alternative(alt ebnf.Alternative) ebnf.Expression {
+	switch len(alt) {
+	case 0:
+		return nil
+	case 1:
+		return alt[0]
+	default:
+		return alt
+	}
+}
+
+// sequence returns the given sequence as an expression; the sole element if
+// only one.
+func sequence(seq ebnf.Sequence) ebnf.Expression {
+	if len(seq) == 1 {
+		return seq[0]
+	}
+	return seq
+}
+
+// appendSeq appends the given expression to the sequence; the elements of
+// sequences are appended individually, and alternatives are grouped.
+func appendSeq(seq ebnf.Sequence, x ebnf.Expression) ebnf.Sequence {
+	switch x := x.(type) {
+	case ebnf.Sequence:
+		return append(seq, x...)
+	case ebnf.Alternative:
+		return append(seq, &ebnf.Group{Lparen: x.Pos(), Body: x})
+	default:
+		return append(seq, x)
+	}
+}
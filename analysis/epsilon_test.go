@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"testing"
+)
+
+func TestEliminateEpsilon(t *testing.T) {
+	golden := []struct {
+		grammar string
+		// Expected grammar after epsilon elimination.
+		want string
+	}{
+		// nullable sequence; the root production remains nullable through an
+		// option.
+		{
+			grammar: `A = B C . B = [ "b" ] . C = [ "c" ] .`,
+			want:    `A = [ B [ C ] | C ] . B = "b" . C = "c" .`,
+		},
+		// nullable sequence of a referenced production.
+		{
+			grammar: `A = "a" S . S = X Y . X = [ "x" ] . Y = { "y" } .`,
+			want:    `A = "a" [ S ] . S = X [ Y ] | Y . X = "x" . Y = "y" { "y" } .`,
+		},
+		// empty alternatives.
+		{
+			grammar: `A = "x" B . B = "b" | "" .`,
+			want:    `A = "x" [ B ] . B = "b" .`,
+		},
+		// productions only matching the empty string are dropped.
+		{
+			grammar: `A = "a" E "b" . E = .`,
+			want:    `A = "a" "b" .`,
+		},
+		{
+			grammar: `A = "a" E . E = "" | [ "" ] .`,
+			want:    `A = "a" .`,
+		},
+		// root productions only matching the empty string are kept.
+		{
+			grammar: `A = .`,
+			want:    `A = .`,
+		},
+		// nullable repetition of the root production.
+		{
+			grammar: `A = { "a" } .`,
+			want:    `A = [ "a" { "a" } ] .`,
+		},
+		// nullable recursive production.
+		{
+			grammar: `S = A . A = "(" A ")" | [ "x" ] .`,
+			want:    `S = [ A ] . A = "(" [ A ] ")" | "x" .`,
+		},
+	}
+	for _, g := range golden {
+		grammar := parseGrammar(t, "test.ebnf", g.grammar)
+		want := parseGrammar(t, "want.ebnf", g.want)
+		got := EliminateEpsilon(grammar)
+		if !Equal(got, want) {
+			t.Errorf("%q: grammar mismatch;\n%s", g.grammar, Diff(want, got))
+		}
+		// only root productions may match the empty string.
+		nullable := Nullable(got)
+		for name := range got {
+			if nullable[name] && len(UsageSites(got)[name]) > 0 {
+				t.Errorf("%q: nullable production %q referenced after epsilon elimination", g.grammar, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mewmew/speak/format"
+	"golang.org/x/exp/ebnf"
+)
+
+// Equal reports whether the grammars a and b are structurally equal; i.e. they
+// define the same production names with the same EBNF text, disregarding
+// source positions.
+func Equal(a, b ebnf.Grammar) bool {
+	return len(Diff(a, b)) == 0
+}
+
+// Diff returns a human-readable diff of the grammars a and b, or the empty
+// string if the grammars are structurally equal. Productions are compared by
+// their EBNF text, in order of production name; productions of a are prefixed
+// by "-" and productions of b by "+"; e.g.
+//
+//	Diff(a, b):
+//	- Expr = Term { "+" Term } .
+//	+ Expr = Term [ "+" Expr ] .
+func Diff(a, b ebnf.Grammar) string {
+	names := make(map[string]bool)
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	buf := &strings.Builder{}
+	for _, name := range sorted {
+		prodA, okA := a[name]
+		prodB, okB := b[name]
+		var textA, textB string
+		if okA {
+			textA = format.SprintProd(prodA)
+		}
+		if okB {
+			textB = format.SprintProd(prodB)
+		}
+		if okA && okB && textA == textB {
+			continue
+		}
+		if okA {
+			buf.WriteString("- " + textA + "\n")
+		}
+		if okB {
+			buf.WriteString("+ " + textB + "\n")
+		}
+	}
+	return buf.String()
+}
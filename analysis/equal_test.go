@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	golden := []struct {
+		a, b string
+		// Expected diff; empty if equal.
+		want string
+	}{
+		// source positions are disregarded.
+		{
+			a: `A = B "c" . B = "b" .`,
+			b: "B = \"b\" .\n\n\tA =\n\t\tB\n\t\t\"c\" .",
+		},
+		// redundant groups are not disregarded.
+		{
+			a:    `A = "a" "b" .`,
+			b:    `A = ( "a" "b" ) .`,
+			want: "- A = \"a\" \"b\" .\n+ A = ( \"a\" \"b\" ) .\n",
+		},
+		// differing productions are ordered by name.
+		{
+			a:    `B = "b" . A = { "a" } .`,
+			b:    `A = [ "a" ] . B = "x" .`,
+			want: "- A = { \"a\" } .\n+ A = [ \"a\" ] .\n- B = \"b\" .\n+ B = \"x\" .\n",
+		},
+		// productions of only one grammar.
+		{
+			a:    `A = "a" . B = "b" .`,
+			b:    `A = "a" . C = "c" .`,
+			want: "- B = \"b\" .\n+ C = \"c\" .\n",
+		},
+		// empty productions.
+		{
+			a:    `A = .`,
+			b:    `A = "" .`,
+			want: "- A = .\n+ A = \"\" .\n",
+		},
+	}
+	for _, g := range golden {
+		a := parseGrammar(t, "a.ebnf", g.a)
+		b := parseGrammar(t, "b.ebnf", g.b)
+		if got := Diff(a, b); got != g.want {
+			t.Errorf("diff mismatch of %q and %q; expected %q, got %q", g.a, g.b, g.want, got)
+		}
+		if got, want := Equal(a, b), len(g.want) == 0; got != want {
+			t.Errorf("equality mismatch of %q and %q; expected %v, got %v", g.a, g.b, want, got)
+		}
+	}
+}
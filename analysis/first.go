@@ -0,0 +1,238 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// A Terminal is a token or character range of a grammar.
+type Terminal struct {
+	// Token literal, or the empty string for character ranges.
+	Token string
+	// Character range (inclusive) if Token is empty.
+	Begin, End rune
+}
+
+// EOF is the terminal denoting end of input.
+var EOF = Terminal{Begin: -1, End: -1}
+
+// String returns the string representation of the terminal.
+func (t Terminal) String() string {
+	switch {
+	case t == EOF:
+		return "EOF"
+	case len(t.Token) > 0:
+		return fmt.Sprintf("%q", t.Token)
+	default:
+		return fmt.Sprintf("%q … %q", string(t.Begin), string(t.End))
+	}
+}
+
+// Match reports whether the given input begins with the terminal.
+func (t Terminal) Match(input []byte) bool {
+	switch {
+	case t == EOF:
+		return len(input) == 0
+	case len(t.Token) > 0:
+		return bytes.HasPrefix(input, []byte(t.Token))
+	default:
+		if len(input) == 0 {
+			return false
+		}
+		r, _ := utf8.DecodeRune(input)
+		return t.Begin <= r && r <= t.End
+	}
+}
+
+// Set is a set of terminals.
+type Set map[Terminal]bool
+
+// add adds the terminals of s2 to s, and reports whether s changed.
+func (s Set) add(s2 Set) bool {
+	changed := false
+	for t := range s2 {
+		if !s[t] {
+			s[t] = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Sorted returns the terminals of the set sorted by string representation.
+func (s Set) Sorted() []Terminal {
+	var ts []Terminal
+	for t := range s {
+		ts = append(ts, t)
+	}
+	sort.Slice(ts, func(i, j int) bool {
+		return ts[i].String() < ts[j].String()
+	})
+	return ts
+}
+
+// Match reports whether the given input begins with any terminal of the set.
+func (s Set) Match(input []byte) bool {
+	for t := range s {
+		if t.Match(input) {
+			return true
+		}
+	}
+	return false
+}
+
+// First returns the FIRST set of each production of the grammar; i.e. the set
+// of terminals that may begin a string derived from the production.
+func First(grammar ebnf.Grammar) map[string]Set {
+	nullable := Nullable(grammar)
+	first := make(map[string]Set)
+	for name := range grammar {
+		first[name] = make(Set)
+	}
+	// Iterate until a fixed point is reached.
+	for changed := true; changed; {
+		changed = false
+		for name, prod := range grammar {
+			if first[name].add(FirstExpr(prod.Expr, first, nullable)) {
+				changed = true
+			}
+		}
+	}
+	return first
+}
+
+// FirstExpr returns the FIRST set of the given expression, based on the given
+// FIRST sets and nullable productions of the grammar.
+func FirstExpr(x ebnf.Expression, first map[string]Set, nullable map[string]bool) Set {
+	s := make(Set)
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+	case ebnf.Alternative:
+		for _, e := range x {
+			s.add(FirstExpr(e, first, nullable))
+		}
+	case ebnf.Sequence:
+		for _, e := range x {
+			s.add(FirstExpr(e, first, nullable))
+			if !IsNullable(e, nullable) {
+				break
+			}
+		}
+	case *ebnf.Name:
+		s.add(first[x.String])
+	case *ebnf.Token:
+		if len(x.String) > 0 {
+			s[Terminal{Token: x.String}] = true
+		}
+	case *ebnf.Range:
+		begin, _ := utf8.DecodeRuneInString(x.Begin.String)
+		end, _ := utf8.DecodeRuneInString(x.End.String)
+		s[Terminal{Begin: begin, End: end}] = true
+	case *ebnf.Group:
+		s.add(FirstExpr(x.Body, first, nullable))
+	case *ebnf.Option:
+		s.add(FirstExpr(x.Body, first, nullable))
+	case *ebnf.Repetition:
+		s.add(FirstExpr(x.Body, first, nullable))
+	case *ebnf.Bad:
+		// invalid expression.
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+	return s
+}
+
+// Follow returns the FOLLOW set of each production of the grammar, with the
+// given start production; i.e. the set of terminals that may directly follow
+// the production in a string derived from the start production. The FOLLOW set
+// contains EOF if the production may end the input.
+func Follow(grammar ebnf.Grammar, start string) map[string]Set {
+	f := &follower{
+		first:    First(grammar),
+		nullable: Nullable(grammar),
+		follow:   make(map[string]Set),
+	}
+	for name := range grammar {
+		f.follow[name] = make(Set)
+	}
+	if _, ok := grammar[start]; ok {
+		f.follow[start][EOF] = true
+	}
+	// Iterate until a fixed point is reached.
+	for changed := true; changed; {
+		f.changed = false
+		for name, prod := range grammar {
+			f.expr(prod.Expr, name, make(Set), true)
+		}
+		changed = f.changed
+	}
+	return f.follow
+}
+
+// follower tracks the state of FOLLOW set computation.
+type follower struct {
+	// FIRST sets of productions.
+	first map[string]Set
+	// Nullable productions.
+	nullable map[string]bool
+	// FOLLOW sets of productions.
+	follow map[string]Set
+	// FOLLOW sets changed during the current iteration.
+	changed bool
+}
+
+// expr updates the FOLLOW sets of productions referenced within the given
+// expression of the production name, where after is the set of terminals that
+// may follow the expression within the production, and atEnd reports whether
+// the expression may end the production.
+func (f *follower) expr(x ebnf.Expression, name string, after Set, atEnd bool) {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+	case ebnf.Alternative:
+		for _, e := range x {
+			f.expr(e, name, after, atEnd)
+		}
+	case ebnf.Sequence:
+		for i := len(x) - 1; i >= 0; i-- {
+			e := x[i]
+			f.expr(e, name, after, atEnd)
+			next := FirstExpr(e, f.first, f.nullable)
+			if IsNullable(e, f.nullable) {
+				next.add(after)
+			} else {
+				atEnd = false
+			}
+			after = next
+		}
+	case *ebnf.Name:
+		if _, ok := f.follow[x.String]; !ok {
+			// missing production.
+			return
+		}
+		if f.follow[x.String].add(after) {
+			f.changed = true
+		}
+		if atEnd && f.follow[x.String].add(f.follow[name]) {
+			f.changed = true
+		}
+	case *ebnf.Token, *ebnf.Range, *ebnf.Bad:
+		// terminal.
+	case *ebnf.Group:
+		f.expr(x.Body, name, after, atEnd)
+	case *ebnf.Option:
+		f.expr(x.Body, name, after, atEnd)
+	case *ebnf.Repetition:
+		// the body may be followed by another repetition of itself.
+		next := FirstExpr(x.Body, f.first, f.nullable)
+		next.add(after)
+		f.expr(x.Body, name, next, atEnd)
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/ebnf"
+)
+
+func TestFirstFollow(t *testing.T) {
+	golden := []struct {
+		grammar string
+		start   string
+		// Expected nullable productions.
+		nullable []string
+		// Expected FIRST and FOLLOW sets, indexed by production name.
+		first, follow map[string]string
+	}{
+		// nullable chain.
+		{
+			grammar:  `S = A B "c" . A = B . B = [ "b" ] .`,
+			start:    "S",
+			nullable: []string{"A", "B"},
+			first:    map[string]string{"S": `"b" "c"`, "A": `"b"`, "B": `"b"`},
+			follow:   map[string]string{"S": `EOF`, "A": `"b" "c"`, "B": `"b" "c"`},
+		},
+		// nullable mutual recursion.
+		{
+			grammar:  `S = A . A = B | "a" . B = [ A ] .`,
+			start:    "S",
+			nullable: []string{"A", "B", "S"},
+			first:    map[string]string{"S": `"a"`, "A": `"a"`, "B": `"a"`},
+			follow:   map[string]string{"S": `EOF`, "A": `EOF`, "B": `EOF`},
+		},
+		// left recursion.
+		{
+			grammar: `E = E "+" T | T . T = "x" | "(" E ")" .`,
+			start:   "E",
+			first:   map[string]string{"E": `"(" "x"`, "T": `"(" "x"`},
+			follow:  map[string]string{"E": `")" "+" EOF`, "T": `")" "+" EOF`},
+		},
+		// empty production.
+		{
+			grammar:  `S = x "a" | "b" x . x = .`,
+			start:    "S",
+			nullable: []string{"x"},
+			first:    map[string]string{"S": `"a" "b"`, "x": ``},
+			follow:   map[string]string{"S": `EOF`, "x": `"a" EOF`},
+		},
+		// repetition.
+		{
+			grammar: `S = { A } "z" . A = "a" … "c" [ B ] . B = "b" .`,
+			start:   "S",
+			first:   map[string]string{"S": `"a" … "c" "z"`, "A": `"a" … "c"`, "B": `"b"`},
+			follow:  map[string]string{"S": `EOF`, "A": `"a" … "c" "z"`, "B": `"a" … "c" "z"`},
+		},
+	}
+	for _, g := range golden {
+		grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(g.grammar))
+		if err != nil {
+			t.Fatalf("unable to parse grammar %q; %v", g.grammar, err)
+		}
+		// Nullable productions.
+		var nullable []string
+		nullableProds := Nullable(grammar)
+		for _, name := range Names(grammar) {
+			if nullableProds[name] {
+				nullable = append(nullable, name)
+			}
+		}
+		if got, want := strings.Join(nullable, " "), strings.Join(g.nullable, " "); got != want {
+			t.Errorf("%q: nullable mismatch; expected [%s], got [%s]", g.grammar, want, got)
+		}
+		// FIRST sets.
+		first := First(grammar)
+		for name, want := range g.first {
+			if got := setString(first[name]); got != want {
+				t.Errorf("%q: FIRST(%s) mismatch; expected {%s}, got {%s}", g.grammar, name, want, got)
+			}
+		}
+		// FOLLOW sets.
+		follow := Follow(grammar, g.start)
+		for name, want := range g.follow {
+			if got := setString(follow[name]); got != want {
+				t.Errorf("%q: FOLLOW(%s) mismatch; expected {%s}, got {%s}", g.grammar, name, want, got)
+			}
+		}
+	}
+}
+
+// setString returns the string representation of the given set of terminals,
+// sorted by string representation.
+func setString(s Set) string {
+	var ts []string
+	for _, t := range s.Sorted() {
+		ts = append(ts, t.String())
+	}
+	return strings.Join(ts, " ")
+}
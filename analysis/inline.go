@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// Inline returns a copy of the grammar where every reference to a production
+// in names is replaced by a copy of the expression of that production. Inlined
+// alternatives are grouped to preserve precedence. The inlined productions
+// remain in the grammar, and productions with empty expressions are not
+// inlined.
+//
+// Inline panics if a production in names is self-referential, either directly
+// or through other inlined productions.
+func Inline(grammar ebnf.Grammar, names []string) ebnf.Grammar {
+	in := &inliner{
+		grammar: grammar,
+		inline:  make(map[string]bool),
+		active:  make(map[string]bool),
+	}
+	for _, name := range names {
+		if _, ok := grammar[name]; !ok {
+			panic(fmt.Errorf("unable to inline production %q; no such production", name))
+		}
+		in.inline[name] = true
+	}
+	g := make(ebnf.Grammar)
+	for _, name := range Names(grammar) {
+		prod := grammar[name]
+		g[name] = &ebnf.Production{
+			Name: clone(prod.Name).(*ebnf.Name),
+			Expr: in.expr(prod.Expr),
+		}
+	}
+	return g
+}
+
+// inliner tracks the state of production inlining.
+type inliner struct {
+	// EBNF grammar.
+	grammar ebnf.Grammar
+	// Productions to inline.
+	inline map[string]bool
+	// Productions currently being inlined.
+	active map[string]bool
+}
+
+// expr returns a copy of the given expression with references to productions
+// inlined.
+func (in *inliner) expr(x ebnf.Expression) ebnf.Expression {
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case ebnf.Alternative:
+		alt := make(ebnf.Alternative, len(x))
+		for i, e := range x {
+			alt[i] = in.expr(e)
+		}
+		return alt
+	case ebnf.Sequence:
+		seq := make(ebnf.Sequence, len(x))
+		for i, e := range x {
+			seq[i] = in.expr(e)
+		}
+		return seq
+	case *ebnf.Name:
+		prod := in.grammar[x.String]
+		if !in.inline[x.String] || prod.Expr == nil {
+			return clone(x)
+		}
+		if in.active[x.String] {
+			panic(fmt.Errorf("unable to inline self-referential production %q", x.String))
+		}
+		in.active[x.String] = true
+		body := in.expr(prod.Expr)
+		in.active[x.String] = false
+		if _, ok := body.(ebnf.Alternative); ok {
+			return &ebnf.Group{Lparen: x.Pos(), Body: body}
+		}
+		return body
+	case *ebnf.Token, *ebnf.Range, *ebnf.Bad:
+		return clone(x)
+	case *ebnf.Group:
+		return &ebnf.Group{Lparen: x.Lparen, Body: in.expr(x.Body)}
+	case *ebnf.Option:
+		return &ebnf.Option{Lbrack: x.Lbrack, Body: in.expr(x.Body)}
+	case *ebnf.Repetition:
+		return &ebnf.Repetition{Lbrace: x.Lbrace, Body: in.expr(x.Body)}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// clone returns a deep copy of the given expression.
+func clone(x ebnf.Expression) ebnf.Expression {
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case ebnf.Alternative:
+		alt := make(ebnf.Alternative, len(x))
+		for i, e := range x {
+			alt[i] = clone(e)
+		}
+		return alt
+	case ebnf.Sequence:
+		seq := make(ebnf.Sequence, len(x))
+		for i, e := range x {
+			seq[i] = clone(e)
+		}
+		return seq
+	case *ebnf.Name:
+		return &ebnf.Name{StringPos: x.StringPos, String: x.String}
+	case *ebnf.Token:
+		return &ebnf.Token{StringPos: x.StringPos, String: x.String}
+	case *ebnf.Range:
+		return &ebnf.Range{Begin: clone(x.Begin).(*ebnf.Token), End: clone(x.End).(*ebnf.Token)}
+	case *ebnf.Group:
+		return &ebnf.Group{Lparen: x.Lparen, Body: clone(x.Body)}
+	case *ebnf.Option:
+		return &ebnf.Option{Lbrack: x.Lbrack, Body: clone(x.Body)}
+	case *ebnf.Repetition:
+		return &ebnf.Repetition{Lbrace: x.Lbrace, Body: clone(x.Body)}
+	case *ebnf.Bad:
+		return &ebnf.Bad{TokPos: x.TokPos, Error: x.Error}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
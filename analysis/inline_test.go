@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mewmew/speak/format"
+	"golang.org/x/exp/ebnf"
+)
+
+func TestInline(t *testing.T) {
+	golden := []struct {
+		grammar string
+		// Productions to inline.
+		names []string
+		// Expected production A after inlining.
+		want string
+	}{
+		// inlined alternatives are grouped to preserve precedence.
+		{
+			grammar: `A = B "c" . B = "a" | "b" .`,
+			names:   []string{"B"},
+			want:    `A = ( "a" | "b" ) "c" .`,
+		},
+		// inlined sequences are not grouped.
+		{
+			grammar: `A = B | "c" . B = "a" "b" .`,
+			names:   []string{"B"},
+			want:    `A = "a" "b" | "c" .`,
+		},
+		// nested inlining.
+		{
+			grammar: `A = [ B ] . B = C "x" . C = "y" | "z" .`,
+			names:   []string{"B", "C"},
+			want:    `A = [ ( "y" | "z" ) "x" ] .`,
+		},
+		// productions not in names are kept as references.
+		{
+			grammar: `A = B C . B = "b" . C = "c" .`,
+			names:   []string{"C"},
+			want:    `A = B "c" .`,
+		},
+		// productions with empty expressions are not inlined.
+		{
+			grammar: `A = "a" B . B = .`,
+			names:   []string{"B"},
+			want:    `A = "a" B .`,
+		},
+	}
+	for _, g := range golden {
+		grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(g.grammar))
+		if err != nil {
+			t.Fatalf("unable to parse grammar %q; %v", g.grammar, err)
+		}
+		got := Inline(grammar, g.names)
+		if s := format.SprintProd(got["A"]); s != g.want {
+			t.Errorf("%q: production mismatch; expected %q, got %q", g.grammar, g.want, s)
+		}
+		// inlined productions remain in the grammar.
+		if len(got) != len(grammar) {
+			t.Errorf("%q: number of productions mismatch; expected %d, got %d", g.grammar, len(grammar), len(got))
+		}
+	}
+}
+
+func TestInlineRecursion(t *testing.T) {
+	golden := []struct {
+		grammar string
+		// Productions to inline.
+		names []string
+		// Expected panic message.
+		want string
+	}{
+		// self recursion.
+		{
+			grammar: `A = "(" A ")" | "x" .`,
+			names:   []string{"A"},
+			want:    `unable to inline self-referential production "A"`,
+		},
+		// mutual recursion.
+		{
+			grammar: `A = B . B = "b" C . C = [ B ] .`,
+			names:   []string{"B", "C"},
+			want:    `unable to inline self-referential production "B"`,
+		},
+		// unknown production.
+		{
+			grammar: `A = "a" .`,
+			names:   []string{"B"},
+			want:    `unable to inline production "B"; no such production`,
+		},
+	}
+	for _, g := range golden {
+		grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(g.grammar))
+		if err != nil {
+			t.Fatalf("unable to parse grammar %q; %v", g.grammar, err)
+		}
+		func() {
+			defer func() {
+				e := recover()
+				if e == nil {
+					t.Errorf("%q: expected panic when inlining %v", g.grammar, g.names)
+					return
+				}
+				if err, ok := e.(error); !ok || err.Error() != g.want {
+					t.Errorf("%q: panic mismatch; expected %q, got %v", g.grammar, g.want, e)
+				}
+			}()
+			Inline(grammar, g.names)
+		}()
+	}
+}
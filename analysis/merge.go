@@ -0,0 +1,95 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// ConflictPolicy specifies how to resolve productions defined in both grammars
+// being merged.
+type ConflictPolicy uint8
+
+// Conflict resolution policies.
+const (
+	// ErrorOnConflict reports an error for each conflicting production, and
+	// keeps the production of the first grammar.
+	ErrorOnConflict ConflictPolicy = iota
+	// PreferA keeps the production of the first grammar.
+	PreferA
+	// PreferB keeps the production of the second grammar.
+	PreferB
+	// MergeAlternatives unions the bodies of the conflicting productions as
+	// alternatives; those of the first grammar before those of the second.
+	MergeAlternatives
+)
+
+// String returns the string representation of the conflict policy.
+func (policy ConflictPolicy) String() string {
+	switch policy {
+	case ErrorOnConflict:
+		return "error"
+	case PreferA:
+		return "prefer-a"
+	case PreferB:
+		return "prefer-b"
+	case MergeAlternatives:
+		return "merge-alternatives"
+	default:
+		return fmt.Sprintf("ConflictPolicy(%d)", uint8(policy))
+	}
+}
+
+// Merge returns the union of the productions of the grammars a and b, with
+// productions defined in both grammars resolved using the given conflict
+// policy. The errors of conflicting productions are returned for the
+// ErrorOnConflict policy, ordered by production name.
+//
+// The input grammars are not modified; productions of the merged grammar are
+// shared with the input grammars, except for those created by the
+// MergeAlternatives policy.
+func Merge(a, b ebnf.Grammar, policy ConflictPolicy) (ebnf.Grammar, []error) {
+	g := make(ebnf.Grammar)
+	for name, prod := range a {
+		g[name] = prod
+	}
+	var errs []error
+	for _, name := range Names(b) {
+		prodB := b[name]
+		prodA, ok := a[name]
+		if !ok {
+			g[name] = prodB
+			continue
+		}
+		switch policy {
+		case ErrorOnConflict:
+			errs = append(errs, errors.Errorf("production rule %q defined in both %v and %v", name, prodA.Pos(), prodB.Pos()))
+		case PreferA:
+			// nothing to do.
+		case PreferB:
+			g[name] = prodB
+		case MergeAlternatives:
+			var alt ebnf.Alternative
+			alt = append(alt, alternatives(prodA)...)
+			alt = append(alt, alternatives(prodB)...)
+			g[name] = &ebnf.Production{Name: prodA.Name, Expr: alt}
+		default:
+			panic(fmt.Errorf("support for conflict policy %v not yet implemented", policy))
+		}
+	}
+	return g, errs
+}
+
+// alternatives returns the alternatives of the body of the given production. An
+// empty body is returned as an empty token, as alternatives may not be nil.
+func alternatives(prod *ebnf.Production) ebnf.Alternative {
+	switch x := prod.Expr.(type) {
+	case nil:
+		return ebnf.Alternative{&ebnf.Token{StringPos: prod.Name.Pos(), String: ""}}
+	case ebnf.Alternative:
+		return x
+	default:
+		return ebnf.Alternative{x}
+	}
+}
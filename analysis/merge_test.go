@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mewmew/speak/format"
+	"golang.org/x/exp/ebnf"
+)
+
+func TestMerge(t *testing.T) {
+	golden := []struct {
+		a, b   string
+		policy ConflictPolicy
+		// Expected merged grammar.
+		want string
+		// Expected errors.
+		errs []string
+	}{
+		// disjoint grammars.
+		{
+			a:      `A = "a" B . B = "b" .`,
+			b:      `C = "c" .`,
+			policy: ErrorOnConflict,
+			want:   `A = "a" B . B = "b" . C = "c" .`,
+		},
+		// conflicts reported in order of production name; the production of the
+		// first grammar is kept.
+		{
+			a:      `A = "a" . B = "b" . C = "c" .`,
+			b:      `C = "z" . A = "x" .`,
+			policy: ErrorOnConflict,
+			want:   `A = "a" . B = "b" . C = "c" .`,
+			errs: []string{
+				`production rule "A" defined in both a.ebnf:1:1 and b.ebnf:1:11`,
+				`production rule "C" defined in both a.ebnf:1:21 and b.ebnf:1:1`,
+			},
+		},
+		// prefer first grammar.
+		{
+			a:      `A = "a" .`,
+			b:      `A = "x" . B = "b" .`,
+			policy: PreferA,
+			want:   `A = "a" . B = "b" .`,
+		},
+		// prefer second grammar.
+		{
+			a:      `A = "a" . B = "b" .`,
+			b:      `A = "x" .`,
+			policy: PreferB,
+			want:   `A = "x" . B = "b" .`,
+		},
+		// prefer second grammar with empty body.
+		{
+			a:      `A = "a" .`,
+			b:      `A = .`,
+			policy: PreferB,
+			want:   `A = .`,
+		},
+		// merge alternatives; those of the first grammar first.
+		{
+			a:      `A = "a" | "b" .`,
+			b:      `A = "c" B . B = "d" .`,
+			policy: MergeAlternatives,
+			want:   `A = "a" | "b" | "c" B . B = "d" .`,
+		},
+		// merge alternatives with empty body.
+		{
+			a:      `A = .`,
+			b:      `A = "a" .`,
+			policy: MergeAlternatives,
+			want:   `A = "" | "a" .`,
+		},
+	}
+	for _, g := range golden {
+		a := parseGrammar(t, "a.ebnf", g.a)
+		b := parseGrammar(t, "b.ebnf", g.b)
+		wantA, wantB := format.Sprint(a), format.Sprint(b)
+		got, errs := Merge(a, b, g.policy)
+		want := parseGrammar(t, "want.ebnf", g.want)
+		if !Equal(got, want) {
+			t.Errorf("%v: merge mismatch of %q and %q;\n%s", g.policy, g.a, g.b, Diff(want, got))
+		}
+		if len(errs) != len(g.errs) {
+			t.Errorf("%v: number of errors mismatch of %q and %q; expected %d, got %d (%v)", g.policy, g.a, g.b, len(g.errs), len(errs), errs)
+			continue
+		}
+		for i, err := range errs {
+			if s := err.Error(); s != g.errs[i] {
+				t.Errorf("%v: error mismatch; expected %q, got %q", g.policy, g.errs[i], s)
+			}
+		}
+		// the input grammars are not modified.
+		if format.Sprint(a) != wantA || format.Sprint(b) != wantB {
+			t.Errorf("%v: input grammars modified by merge of %q and %q", g.policy, g.a, g.b)
+		}
+	}
+}
+
+// parseGrammar parses the given EBNF grammar source.
+func parseGrammar(t *testing.T, filename, src string) ebnf.Grammar {
+	t.Helper()
+	grammar, err := ebnf.Parse(filename, strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unable to parse grammar %q; %v", src, err)
+	}
+	return grammar
+}
@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// Nullable returns the set of productions of the grammar which may derive the
+// empty string.
+func Nullable(grammar ebnf.Grammar) map[string]bool {
+	nullable := make(map[string]bool)
+	// Iterate until a fixed point is reached.
+	for changed := true; changed; {
+		changed = false
+		for name, prod := range grammar {
+			if nullable[name] {
+				continue
+			}
+			if IsNullable(prod.Expr, nullable) {
+				nullable[name] = true
+				changed = true
+			}
+		}
+	}
+	return nullable
+}
+
+// IsNullable reports whether the given expression may derive the empty string,
+// based on the given set of nullable productions.
+func IsNullable(x ebnf.Expression, nullable map[string]bool) bool {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+		return true
+	case ebnf.Alternative:
+		for _, e := range x {
+			if IsNullable(e, nullable) {
+				return true
+			}
+		}
+		return false
+	case ebnf.Sequence:
+		for _, e := range x {
+			if !IsNullable(e, nullable) {
+				return false
+			}
+		}
+		return true
+	case *ebnf.Name:
+		return nullable[x.String]
+	case *ebnf.Token:
+		return len(x.String) == 0
+	case *ebnf.Range:
+		return false
+	case *ebnf.Group:
+		return IsNullable(x.Body, nullable)
+	case *ebnf.Option:
+		return true
+	case *ebnf.Repetition:
+		return true
+	case *ebnf.Bad:
+		return false
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
@@ -0,0 +1,23 @@
+package analysis
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// ParseFile parses the given EBNF grammar file.
+func ParseFile(grammarPath string) (ebnf.Grammar, error) {
+	f, err := os.Open(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	grammar, err := ebnf.Parse(grammarPath, bufio.NewReader(f))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return grammar, nil
+}
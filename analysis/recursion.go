@@ -0,0 +1,131 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// DetectLeftRecursion returns the left-recursive cycles of the grammar. Each
+// cycle is reported as the sorted list of production names of a strongly
+// connected component in the left-corner graph of the grammar; i.e. the graph
+// with an edge from A to B if B may appear in the leftmost position of A.
+func DetectLeftRecursion(grammar ebnf.Grammar) [][]string {
+	nullable := Nullable(grammar)
+	g := make(map[string][]string)
+	for name, prod := range grammar {
+		g[name] = leftCorners(prod.Expr, nullable, nil)
+	}
+	var cycles [][]string
+	for _, scc := range stronglyConnected(g) {
+		if len(scc) == 1 && !contains(g[scc[0]], scc[0]) {
+			// not recursive.
+			continue
+		}
+		cycles = append(cycles, scc)
+	}
+	return cycles
+}
+
+// leftCorners appends the names of productions that may appear in the leftmost
+// position of the given expression to names.
+func leftCorners(x ebnf.Expression, nullable map[string]bool, names []string) []string {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+	case ebnf.Alternative:
+		for _, e := range x {
+			names = leftCorners(e, nullable, names)
+		}
+	case ebnf.Sequence:
+		for _, e := range x {
+			names = leftCorners(e, nullable, names)
+			if !IsNullable(e, nullable) {
+				break
+			}
+		}
+	case *ebnf.Name:
+		if !contains(names, x.String) {
+			names = append(names, x.String)
+		}
+	case *ebnf.Token, *ebnf.Range, *ebnf.Bad:
+		// terminal.
+	case *ebnf.Group:
+		names = leftCorners(x.Body, nullable, names)
+	case *ebnf.Option:
+		names = leftCorners(x.Body, nullable, names)
+	case *ebnf.Repetition:
+		names = leftCorners(x.Body, nullable, names)
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+	return names
+}
+
+// stronglyConnected returns the strongly connected components of the given
+// directed graph, using Tarjan's algorithm. The nodes of each component are
+// sorted by name, and the components are sorted by their first node.
+func stronglyConnected(g map[string][]string) [][]string {
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		sccs    [][]string
+	)
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range g[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+		if lowlink[v] != index[v] {
+			return
+		}
+		var scc []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		sort.Strings(scc)
+		sccs = append(sccs, scc)
+	}
+	var nodes []string
+	for v := range g {
+		nodes = append(nodes, v)
+	}
+	sort.Strings(nodes)
+	for _, v := range nodes {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	sort.Slice(sccs, func(i, j int) bool {
+		return sccs[i][0] < sccs[j][0]
+	})
+	return sccs
+}
+
+// contains reports whether the list of names contains the given name.
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// UsageSite is a reference to a production within the expression of a
+// production.
+type UsageSite struct {
+	// Name of the enclosing production.
+	Production string
+	// Path to the reference within the expression of the enclosing production;
+	// e.g. "Alt[2].Seq[0]". The path is empty if the expression is the
+	// reference itself.
+	ExprPath string
+	// Name node of the reference.
+	Name *ebnf.Name
+}
+
+// UsageSites returns the usage sites of each production referenced in the
+// grammar, indexed by production name. The usage sites of each production are
+// ordered by enclosing production name and position within its expression.
+func UsageSites(grammar ebnf.Grammar) map[string][]UsageSite {
+	sites := make(map[string][]UsageSite)
+	for _, name := range Names(grammar) {
+		walkNames(grammar[name].Expr, "", func(x *ebnf.Name, path string) {
+			site := UsageSite{
+				Production: name,
+				ExprPath:   path,
+				Name:       x,
+			}
+			sites[x.String] = append(sites[x.String], site)
+		})
+	}
+	return sites
+}
+
+// walkNames invokes f for each production name referenced within the given
+// expression, with the path to the reference.
+func walkNames(x ebnf.Expression, path string, f func(x *ebnf.Name, path string)) {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+	case ebnf.Alternative:
+		for i, e := range x {
+			walkNames(e, joinPath(path, fmt.Sprintf("Alt[%d]", i)), f)
+		}
+	case ebnf.Sequence:
+		for i, e := range x {
+			walkNames(e, joinPath(path, fmt.Sprintf("Seq[%d]", i)), f)
+		}
+	case *ebnf.Name:
+		f(x, path)
+	case *ebnf.Token, *ebnf.Range, *ebnf.Bad:
+		// terminal.
+	case *ebnf.Group:
+		walkNames(x.Body, joinPath(path, "Group"), f)
+	case *ebnf.Option:
+		walkNames(x.Body, joinPath(path, "Opt"), f)
+	case *ebnf.Repetition:
+		walkNames(x.Body, joinPath(path, "Rep"), f)
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// joinPath joins the given expression path with the path element.
+func joinPath(path, elem string) string {
+	if len(path) == 0 {
+		return elem
+	}
+	return path + "." + elem
+}
@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/ebnf"
+)
+
+func TestUsageSites(t *testing.T) {
+	golden := []struct {
+		grammar string
+		// Expected usage sites, indexed by production name; each site formatted
+		// as "Production:ExprPath@line:column".
+		want map[string][]string
+	}{
+		// reference as expression.
+		{
+			grammar: `A = B . B = "b" .`,
+			want: map[string][]string{
+				"B": {"A:@1:5"},
+			},
+		},
+		// alternatives and sequences.
+		{
+			grammar: `A = "a" | "b" | C D . C = "c" . D = "d" .`,
+			want: map[string][]string{
+				"C": {"A:Alt[2].Seq[0]@1:17"},
+				"D": {"A:Alt[2].Seq[1]@1:19"},
+			},
+		},
+		// groups, options and repetitions.
+		{
+			grammar: `A = ( B ) [ B ] { "x" B } . B = "b" .`,
+			want: map[string][]string{
+				"B": {"A:Seq[0].Group@1:7", "A:Seq[1].Opt@1:13", "A:Seq[2].Rep.Seq[1]@1:23"},
+			},
+		},
+		// sites ordered by enclosing production name and position.
+		{
+			grammar: `Z = X X . Y = X . X = "x" | Y .`,
+			want: map[string][]string{
+				"X": {"Y:@1:15", "Z:Seq[0]@1:5", "Z:Seq[1]@1:7"},
+				"Y": {"X:Alt[1]@1:29"},
+			},
+		},
+		// undefined productions.
+		{
+			grammar: `A = undefined .`,
+			want: map[string][]string{
+				"undefined": {"A:@1:5"},
+			},
+		},
+	}
+	for _, g := range golden {
+		grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(g.grammar))
+		if err != nil {
+			t.Fatalf("unable to parse grammar %q; %v", g.grammar, err)
+		}
+		sites := UsageSites(grammar)
+		if len(sites) != len(g.want) {
+			t.Errorf("%q: number of referenced productions mismatch; expected %d, got %d", g.grammar, len(g.want), len(sites))
+		}
+		for name, want := range g.want {
+			var got []string
+			for _, site := range sites[name] {
+				if site.Name.String != name {
+					t.Errorf("%q: name mismatch of usage site; expected %q, got %q", g.grammar, name, site.Name.String)
+				}
+				pos := site.Name.Pos()
+				got = append(got, fmt.Sprintf("%s:%s@%d:%d", site.Production, site.ExprPath, pos.Line, pos.Column))
+			}
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("%q: usage sites mismatch of %q; expected %q, got %q", g.grammar, name, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// Verify verifies the given grammar, such that all production rules are
+// reachable from one of the given start production rules. The skip production
+// rule, which is evaluated implicitly between tokens, is removed from the
+// grammar during verification.
+//
+// The errors of the returned error list may be accessed using SplitErrors.
+func Verify(grammar ebnf.Grammar, skipRule string, starts ...string) error {
+	// Remove skip before validate.
+	skip, ok := grammar[skipRule]
+	// TODO: Remove skip production rules recursively before validate.
+	if ok {
+		delete(grammar, skipRule)
+		// Add skip after validate.
+		defer func() {
+			grammar[skipRule] = skip
+		}()
+	}
+	if len(starts) == 1 {
+		if err := ebnf.Verify(grammar, starts[0]); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	// Verify from a root production rule referencing each start production rule,
+	// named so as not to clash with the production rules of the grammar.
+	const root = "Start·"
+	var alt ebnf.Alternative
+	for _, start := range starts {
+		if _, ok := grammar[start]; !ok {
+			return errors.Errorf("unable to locate start production rule %q", start)
+		}
+		alt = append(alt, &ebnf.Name{String: start})
+	}
+	grammar[root] = &ebnf.Production{Name: &ebnf.Name{String: root}, Expr: alt}
+	defer delete(grammar, root)
+	if err := ebnf.Verify(grammar, root); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// SplitErrors returns the errors of the given error list of the ebnf package.
+func SplitErrors(err error) []error {
+	// The ebnf package returns an unexported list of errors; use reflection to
+	// access each error.
+	v := reflect.ValueOf(errors.Cause(err))
+	if v.Kind() != reflect.Slice {
+		return []error{err}
+	}
+	var errs []error
+	for i := 0; i < v.Len(); i++ {
+		if e, ok := v.Index(i).Interface().(error); ok {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,168 @@
+// Package bnf implements conversion of EBNF grammars to BNF.
+//
+// Repetitions, options and grouped alternatives are rewritten as new
+// production rules, and the empty string is denoted by the empty token "".
+//
+//	{ x }     =>   P_repN = x P_repN | "" .
+//	[ x ]     =>   P_optN = x | "" .
+//	( x | y ) =>   P_grpN = x | y .
+package bnf
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// Production is a BNF production rule.
+type Production struct {
+	// Production name.
+	Name string
+	// Alternatives of the production, each a sequence of names, tokens and
+	// ranges. A nil slice denotes an empty production.
+	Alts [][]ebnf.Expression
+}
+
+// Convert converts the production rules of the given EBNF grammar to BNF, in
+// source order. Each production rule is followed by the production rules
+// generated for its repetitions, options and groups, which are named after the
+// production rule.
+func Convert(grammar ebnf.Grammar) []*Production {
+	c := newConverter(grammar)
+	return c.convert()
+}
+
+// IsEmpty reports whether the given expression of a BNF alternative denotes the
+// empty string.
+func IsEmpty(x ebnf.Expression) bool {
+	tok, ok := x.(*ebnf.Token)
+	return ok && len(tok.String) == 0
+}
+
+// converter converts EBNF production rules to BNF.
+type converter struct {
+	// EBNF grammar.
+	grammar ebnf.Grammar
+	// Production names in use, including generated ones.
+	used map[string]bool
+	// Name of the production rule currently being converted.
+	cur string
+	// Generated production rules of the production currently being converted.
+	generated []*Production
+}
+
+// newConverter returns a new converter for the given EBNF grammar.
+func newConverter(grammar ebnf.Grammar) *converter {
+	used := make(map[string]bool)
+	for name := range grammar {
+		used[name] = true
+	}
+	return &converter{
+		grammar: grammar,
+		used:    used,
+	}
+}
+
+// convert converts the production rules of the grammar to BNF, in source
+// order. Each production rule is followed by the production rules generated
+// for its repetitions, options and groups.
+func (c *converter) convert() []*Production {
+	var prods []*ebnf.Production
+	for _, prod := range c.grammar {
+		prods = append(prods, prod)
+	}
+	sort.Slice(prods, func(i, j int) bool {
+		return prods[i].Pos().Offset < prods[j].Pos().Offset
+	})
+	var bnf []*Production
+	for _, prod := range prods {
+		c.cur = prod.Name.String
+		c.generated = nil
+		p := &Production{Name: c.cur}
+		if prod.Expr != nil {
+			p.Alts = c.alts(prod.Expr)
+		}
+		bnf = append(bnf, p)
+		bnf = append(bnf, c.generated...)
+	}
+	return bnf
+}
+
+// alts returns the alternatives of the given expression, each a sequence of
+// names, tokens and ranges.
+func (c *converter) alts(x ebnf.Expression) [][]ebnf.Expression {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		var alts [][]ebnf.Expression
+		for _, e := range x {
+			alts = append(alts, c.alts(e)...)
+		}
+		return alts
+	case *ebnf.Group:
+		return c.alts(x.Body)
+	default:
+		return [][]ebnf.Expression{c.seq(x)}
+	}
+}
+
+// seq returns the given expression as a sequence of names, tokens and ranges.
+func (c *converter) seq(x ebnf.Expression) []ebnf.Expression {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		// ( x | y ) => P_grpN = x | y .
+		return []ebnf.Expression{c.newProd("grp", c.alts(x))}
+	case ebnf.Sequence:
+		var seq []ebnf.Expression
+		for _, e := range x {
+			seq = append(seq, c.seq(e)...)
+		}
+		return seq
+	case *ebnf.Name, *ebnf.Token, *ebnf.Range:
+		return []ebnf.Expression{x}
+	case *ebnf.Group:
+		return c.seq(x.Body)
+	case *ebnf.Option:
+		// [ x ] => P_optN = x | "" .
+		alts := append(c.alts(x.Body), []ebnf.Expression{empty()})
+		return []ebnf.Expression{c.newProd("opt", alts)}
+	case *ebnf.Repetition:
+		// { x } => P_repN = x P_repN | "" .
+		name := c.freshName("rep")
+		rep := &ebnf.Name{String: name}
+		var alts [][]ebnf.Expression
+		for _, alt := range c.alts(x.Body) {
+			alts = append(alts, append(alt, rep))
+		}
+		alts = append(alts, []ebnf.Expression{empty()})
+		c.generated = append(c.generated, &Production{Name: name, Alts: alts})
+		return []ebnf.Expression{rep}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// newProd adds a generated production rule with the given alternatives, and
+// returns a reference to it.
+func (c *converter) newProd(kind string, alts [][]ebnf.Expression) *ebnf.Name {
+	name := c.freshName(kind)
+	c.generated = append(c.generated, &Production{Name: name, Alts: alts})
+	return &ebnf.Name{String: name}
+}
+
+// freshName returns an unused production name for a production rule of the
+// given kind (rep, opt or grp) generated for the current production rule.
+func (c *converter) freshName(kind string) string {
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s_%s%d", c.cur, kind, i)
+		if !c.used[name] {
+			c.used[name] = true
+			return name
+		}
+	}
+}
+
+// empty returns a token denoting the empty string.
+func empty() *ebnf.Token {
+	return &ebnf.Token{String: ""}
+}
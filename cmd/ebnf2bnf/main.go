@@ -0,0 +1,93 @@
+// The ebnf2bnf tool converts EBNF grammars to BNF.
+//
+// Repetitions, options and grouped alternatives are rewritten as new
+// production rules, and the empty string is denoted by the empty token "".
+//
+//	{ x }     =>   P_repN = x P_repN | "" .
+//	[ x ]     =>   P_optN = x | "" .
+//	( x | y ) =>   P_grpN = x | y .
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/bnf"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Usage: ebnf2bnf [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+	)
+	flag.StringVar(&output, "o", "", "output path (default stdout)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Convert grammar to BNF.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	w := os.Stdout
+	if len(output) > 0 {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	for i, prod := range bnf.Convert(grammar) {
+		if i != 0 {
+			fmt.Fprintln(bw)
+		}
+		writeProd(bw, prod)
+	}
+	if err := bw.Flush(); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// writeProd writes the given BNF production rule in EBNF syntax.
+func writeProd(w io.Writer, prod *bnf.Production) {
+	fmt.Fprintln(w, prod.Name)
+	for i, alt := range prod.Alts {
+		sep := "|"
+		if i == 0 {
+			sep = "="
+		}
+		fmt.Fprintf(w, "\t%s", sep)
+		for _, e := range alt {
+			fmt.Fprintf(w, " %s", format.SprintExpr(e))
+		}
+		fmt.Fprintln(w)
+	}
+	if len(prod.Alts) == 0 {
+		fmt.Fprintln(w, "\t=")
+	}
+	fmt.Fprintln(w, ".")
+}
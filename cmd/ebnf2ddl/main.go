@@ -0,0 +1,298 @@
+// The ebnf2ddl tool converts EBNF grammars of data formats to SQL DDL
+// statements.
+//
+// Syntactic productions are converted to tables with an id primary key, where
+// sequences map to one column per referenced production, alternatives to a
+// CHECK constraint, and repetitions to junction tables. References to lexical
+// productions map to TEXT columns, and to syntactic productions to foreign key
+// columns. Tokens of sequences are considered punctuation and are omitted,
+// while alternative tokens map to a VARCHAR value column restricted to the
+// tokens.
+//
+//	Entry = Key "=" Value .
+//	Value = string | number | "true" | "false" .
+//
+// is converted to
+//
+//	CREATE TABLE "Entry" (
+//	    "id" INTEGER PRIMARY KEY,
+//	    "key_id" INTEGER NOT NULL,
+//	    "value_id" INTEGER NOT NULL
+//	);
+//
+//	CREATE TABLE "Value" (
+//	    "id" INTEGER PRIMARY KEY,
+//	    "string" TEXT,
+//	    "number" TEXT,
+//	    "value" VARCHAR(5) CHECK ("value" IN ('true', 'false')),
+//	    CHECK ((CASE WHEN "string" IS NULL THEN 0 ELSE 1 END) + ... = 1)
+//	);
+//
+// Foreign key constraints are added with ALTER TABLE after all tables have been
+// created, to support recursive productions (e.g. PostgreSQL and MySQL).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+var (
+	// warn is a logger with the "ebnf2ddl:" prefix which logs warning messages
+	// to standard error.
+	warn = log.New(os.Stderr, term.RedBold("ebnf2ddl:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Usage: ebnf2ddl [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+	)
+	flag.StringVar(&output, "o", "schema.sql", "output path")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Convert grammar to SQL DDL.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	ddl := sqlDDL(grammar)
+	if err := ioutil.WriteFile(output, []byte(ddl), 0644); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// table is an SQL table.
+type table struct {
+	// Table name.
+	name string
+	// Column definitions.
+	columns []string
+	// Table constraints.
+	constraints []string
+	// Foreign keys, added after all tables have been created.
+	foreignKeys []foreignKey
+	// Number of uses of each column name.
+	uses map[string]int
+}
+
+// foreignKey is a foreign key constraint of a table column.
+type foreignKey struct {
+	// Column name.
+	column string
+	// Referenced table.
+	ref string
+}
+
+// sqlDDL returns the SQL DDL statements of the given grammar.
+func sqlDDL(grammar ebnf.Grammar) string {
+	var tables []*table
+	for _, name := range analysis.Names(grammar) {
+		if !analysis.IsLexical(name) {
+			tables = append(tables, prodTables(grammar[name])...)
+		}
+	}
+	buf := &strings.Builder{}
+	for _, t := range tables {
+		fmt.Fprintf(buf, "CREATE TABLE %s (\n", quoteIdent(t.name))
+		defs := append(append([]string{}, t.columns...), t.constraints...)
+		for i, def := range defs {
+			buf.WriteString("\t" + def)
+			if i != len(defs)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(");\n\n")
+	}
+	for _, t := range tables {
+		for _, fk := range t.foreignKeys {
+			fmt.Fprintf(buf, "ALTER TABLE %s ADD FOREIGN KEY (%s) REFERENCES %s (%s);\n", quoteIdent(t.name), quoteIdent(fk.column), quoteIdent(fk.ref), quoteIdent("id"))
+		}
+	}
+	return buf.String()
+}
+
+// prodTables returns the table of the given syntactic production, followed by
+// the junction tables of its repetitions.
+func prodTables(prod *ebnf.Production) []*table {
+	name := prod.Name.String
+	t := &table{
+		name:    name,
+		columns: []string{quoteIdent("id") + " INTEGER PRIMARY KEY"},
+	}
+	tables := []*table{t}
+	switch x := prod.Expr.(type) {
+	case nil:
+		// empty production.
+	case ebnf.Alternative:
+		choiceColumns(t, x)
+	case ebnf.Sequence:
+		for _, e := range x {
+			tables = append(tables, seqColumn(t, e)...)
+		}
+	default:
+		tables = append(tables, seqColumn(t, x)...)
+	}
+	return tables
+}
+
+// seqColumn adds the column of the given element of a sequence to the table,
+// and returns the junction tables of repetitions.
+func seqColumn(t *table, x ebnf.Expression) []*table {
+	switch x := x.(type) {
+	case *ebnf.Token:
+		// punctuation.
+	case *ebnf.Name:
+		nameColumn(t, x.String, true)
+	case *ebnf.Option:
+		if name, ok := x.Body.(*ebnf.Name); ok {
+			nameColumn(t, name.String, false)
+			return nil
+		}
+		warn.Printf("%v: optional expression %v in production %q has no column name; omitted", x.Pos(), format.SprintExpr(x), t.name)
+	case *ebnf.Repetition:
+		if name, ok := x.Body.(*ebnf.Name); ok {
+			return []*table{junctionTable(t.name, name.String)}
+		}
+		warn.Printf("%v: repeated expression %v in production %q has no junction table name; omitted", x.Pos(), format.SprintExpr(x), t.name)
+	default:
+		warn.Printf("%v: expression %v in production %q has no column name; omitted", x.Pos(), format.SprintExpr(x), t.name)
+	}
+	return nil
+}
+
+// choiceColumns adds the columns of the given alternatives to the table; one
+// nullable column per referenced production, and a value column of tokens. A
+// CHECK constraint ensures that exactly one column is set.
+func choiceColumns(t *table, alt ebnf.Alternative) {
+	var set []string
+	var tokens []string
+	maxLen := 0
+	for _, e := range alt {
+		switch e := e.(type) {
+		case *ebnf.Token:
+			tokens = append(tokens, quoteString(e.String))
+			maxLen = max(maxLen, utf8.RuneCountInString(e.String))
+		case *ebnf.Name:
+			set = append(set, nameColumn(t, e.String, false))
+		default:
+			warn.Printf("%v: alternative %v in production %q has no column name; omitted", e.Pos(), format.SprintExpr(e), t.name)
+		}
+	}
+	if len(tokens) > 0 {
+		value := quoteIdent("value")
+		t.columns = append(t.columns, fmt.Sprintf("%s VARCHAR(%d) CHECK (%s IN (%s))", value, maxLen, value, strings.Join(tokens, ", ")))
+		set = append(set, value)
+	}
+	if len(set) < 2 {
+		if len(set) == 1 {
+			// a single column must be set.
+			t.columns[len(t.columns)-1] += " NOT NULL"
+		}
+		return
+	}
+	var terms []string
+	for _, column := range set {
+		terms = append(terms, fmt.Sprintf("(CASE WHEN %s IS NULL THEN 0 ELSE 1 END)", column))
+	}
+	t.constraints = append(t.constraints, fmt.Sprintf("CHECK (%s = 1)", strings.Join(terms, " + ")))
+}
+
+// nameColumn adds the column of the given referenced production to the table,
+// and returns the quoted column name. References to lexical productions map to
+// TEXT columns, and to syntactic productions to foreign key columns. Repeated
+// references within the same table are numbered (e.g. value_id_2).
+func nameColumn(t *table, name string, required bool) string {
+	column := columnName(name)
+	if t.uses == nil {
+		t.uses = make(map[string]int)
+	}
+	t.uses[column]++
+	if n := t.uses[column]; n > 1 {
+		column = fmt.Sprintf("%s_%d", column, n)
+	}
+	typ := "INTEGER"
+	if analysis.IsLexical(name) {
+		typ = "TEXT"
+	} else {
+		t.foreignKeys = append(t.foreignKeys, foreignKey{column: column, ref: name})
+	}
+	def := quoteIdent(column) + " " + typ
+	if required {
+		def += " NOT NULL"
+	}
+	t.columns = append(t.columns, def)
+	return quoteIdent(column)
+}
+
+// junctionTable returns the junction table of the repeated production within
+// the given parent production, ordered by position.
+func junctionTable(parent, name string) *table {
+	parentColumn := columnName(parent)
+	t := &table{
+		uses: map[string]int{parentColumn: 1},
+		name: parent + "_" + name,
+		columns: []string{
+			quoteIdent(parentColumn) + " INTEGER NOT NULL",
+			quoteIdent("position") + " INTEGER NOT NULL",
+		},
+		constraints: []string{
+			fmt.Sprintf("PRIMARY KEY (%s, %s)", quoteIdent(parentColumn), quoteIdent("position")),
+		},
+		foreignKeys: []foreignKey{{column: parentColumn, ref: parent}},
+	}
+	nameColumn(t, name, true)
+	return t
+}
+
+// ### [ Helper functions ] ####################################################
+
+// columnName returns the column name of references to the given production;
+// the lowercase production name, suffixed by "_id" for syntactic productions.
+//
+//	Key    => key_id
+//	string => string
+func columnName(name string) string {
+	if analysis.IsLexical(name) {
+		return name
+	}
+	return strings.ToLower(name) + "_id"
+}
+
+// quoteIdent returns the given SQL identifier in double quotes.
+func quoteIdent(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// quoteString returns the given SQL string literal in single quotes.
+func quoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,187 @@
+// The ebnf2jsonschema tool converts EBNF grammars of data formats to JSON
+// Schema.
+//
+// Syntactic productions are converted to definitions, where sequences map to
+// objects with one property per referenced production, alternatives to oneOf,
+// repetitions to arrays, options to oneOf with null, and tokens to const string
+// values. Lexical productions are converted to strings.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+var (
+	// warn is a logger with the "ebnf2jsonschema:" prefix which logs warning
+	// messages to standard error.
+	warn = log.New(os.Stderr, term.RedBold("ebnf2jsonschema:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Usage: ebnf2jsonschema [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Start production rule.
+		start string
+	)
+	flag.StringVar(&output, "o", "schema.json", "output path")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Convert grammar to JSON Schema.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if _, ok := grammar[start]; !ok {
+		log.Fatalf("unable to locate start production rule %q in grammar %q", start, grammarPath)
+	}
+	schema := jsonSchema(grammar, start)
+	buf, err := json.MarshalIndent(schema, "", "\t")
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	buf = append(buf, '\n')
+	if err := ioutil.WriteFile(output, buf, 0644); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// schema is a JSON Schema.
+type schema map[string]interface{}
+
+// jsonSchema returns the JSON Schema of the given grammar, with the start
+// production rule as root.
+func jsonSchema(grammar ebnf.Grammar, start string) schema {
+	defs := make(map[string]schema)
+	for _, name := range analysis.Names(grammar) {
+		prod := grammar[name]
+		if analysis.IsLexical(name) {
+			defs[name] = schema{"type": "string"}
+			continue
+		}
+		defs[name] = prodSchema(prod)
+	}
+	return schema{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$ref":    ref(start),
+		"$defs":   defs,
+	}
+}
+
+// prodSchema returns the JSON Schema of the given syntactic production.
+func prodSchema(prod *ebnf.Production) schema {
+	if prod.Expr == nil {
+		return schema{"type": "null"}
+	}
+	name := prod.Name.String
+	if seq, ok := prod.Expr.(ebnf.Sequence); ok {
+		return objectSchema(name, seq)
+	}
+	return exprSchema(name, prod.Expr)
+}
+
+// objectSchema returns the JSON Schema object of the given sequence, with one
+// property per referenced production. Tokens of the sequence are considered
+// punctuation and are omitted.
+func objectSchema(prodName string, seq ebnf.Sequence) schema {
+	props := make(map[string]schema)
+	var required []string
+	for _, e := range seq {
+		switch e := e.(type) {
+		case *ebnf.Token:
+			// punctuation.
+		case *ebnf.Name:
+			props[e.String] = schema{"$ref": ref(e.String)}
+			required = append(required, e.String)
+		case *ebnf.Option:
+			if name, ok := e.Body.(*ebnf.Name); ok {
+				props[name.String] = schema{"$ref": ref(name.String)}
+				continue
+			}
+			warn.Printf("%v: optional expression %v in production %q has no property name; omitted", e.Pos(), format.SprintExpr(e), prodName)
+		case *ebnf.Repetition:
+			if name, ok := e.Body.(*ebnf.Name); ok {
+				props[name.String] = schema{"type": "array", "items": schema{"$ref": ref(name.String)}}
+				continue
+			}
+			warn.Printf("%v: repeated expression %v in production %q has no property name; omitted", e.Pos(), format.SprintExpr(e), prodName)
+		default:
+			warn.Printf("%v: expression %v in production %q has no property name; omitted", e.Pos(), format.SprintExpr(e), prodName)
+		}
+	}
+	s := schema{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// exprSchema returns the JSON Schema of the given expression of the syntactic
+// production.
+func exprSchema(prodName string, x ebnf.Expression) schema {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		var alts []schema
+		for _, e := range x {
+			alts = append(alts, exprSchema(prodName, e))
+		}
+		return schema{"oneOf": alts}
+	case ebnf.Sequence:
+		return objectSchema(prodName, x)
+	case *ebnf.Name:
+		return schema{"$ref": ref(x.String)}
+	case *ebnf.Token:
+		return schema{"const": x.String}
+	case *ebnf.Range:
+		warn.Printf("%v: character range %v in production %q has no JSON Schema equivalent; using string", x.Pos(), format.SprintExpr(x), prodName)
+		return schema{"type": "string"}
+	case *ebnf.Group:
+		return exprSchema(prodName, x.Body)
+	case *ebnf.Option:
+		return schema{"oneOf": []schema{exprSchema(prodName, x.Body), {"type": "null"}}}
+	case *ebnf.Repetition:
+		return schema{"type": "array", "items": exprSchema(prodName, x.Body)}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// ref returns a JSON Schema reference to the definition of the given
+// production.
+func ref(name string) string {
+	return "#/$defs/" + name
+}
@@ -0,0 +1,110 @@
+// The ebnf2treesitter tool converts EBNF grammars to the grammar.json format of
+// Tree-sitter, as accepted by tree-sitter generate.
+//
+// Syntactic productions are converted to rules, where sequences map to seq,
+// alternatives to choice, repetitions to repeat, options to optional (a choice
+// with blank), tokens to strings and character ranges to patterns. The start
+// production is the first rule of the grammar.
+//
+// Lexical productions referenced from syntactic productions are converted to
+// token(prec(N, ...)) rules, with the lexical productions they reference
+// inlined. Lexical precedence follows source order, such that earlier lexical
+// productions take precedence over later ones, as with the ordered choice of
+// the runtime evaluator of the speak tool. Lexical precedences are negative, so
+// that literal tokens (e.g. keywords) take precedence over lexical productions.
+// The skip production, if present, is used as extras.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Usage: ebnf2treesitter [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Grammar name.
+		name string
+		// Start production rule.
+		start string
+		// Skip production rule.
+		skipRule string
+	)
+	flag.StringVar(&output, "o", "grammar.json", "output path")
+	flag.StringVar(&name, "name", "", "grammar name (default base name of FILE)")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Convert grammar to Tree-sitter.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if _, ok := grammar[start]; !ok {
+		log.Fatalf("unable to locate start production rule %q in grammar %q", start, grammarPath)
+	}
+	if len(name) == 0 {
+		name = grammarName(grammarPath)
+	}
+	g, err := convert(grammar, name, start, skipRule)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	buf, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	buf = append(buf, '\n')
+	if err := ioutil.WriteFile(output, buf, 0644); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// grammarName returns the Tree-sitter grammar name of the given grammar path;
+// the base name without extension, with non-identifier characters replaced by
+// underscores.
+func grammarName(grammarPath string) string {
+	base := filepath.Base(grammarPath)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, base)
+	if len(name) == 0 || ('0' <= name[0] && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+var (
+	// warn is a logger with the "ebnf2treesitter:" prefix which logs warning
+	// messages to standard error.
+	warn = log.New(os.Stderr, term.RedBold("ebnf2treesitter:")+" ", 0)
+)
+
+// Grammar is a Tree-sitter grammar, in the grammar.json format.
+type Grammar struct {
+	// Grammar name.
+	Name string `json:"name"`
+	// Grammar rules; the first rule is the start rule.
+	Rules Rules `json:"rules"`
+	// Tokens which may appear anywhere in the input (e.g. whitespace).
+	Extras []*Rule `json:"extras"`
+	// Intended conflicts between rules.
+	Conflicts [][]string `json:"conflicts"`
+	// Tokens of external scanners.
+	Externals []*Rule `json:"externals"`
+	// Rules inlined at their usage sites.
+	Inline []string `json:"inline"`
+	// Supertype rules.
+	Supertypes []string `json:"supertypes"`
+}
+
+// Rules is an ordered list of named Tree-sitter rules, encoded as a JSON object.
+type Rules []*NamedRule
+
+// NamedRule is a named Tree-sitter rule.
+type NamedRule struct {
+	// Rule name.
+	Name string
+	// Rule definition.
+	Rule *Rule
+}
+
+// MarshalJSON encodes the rules as a JSON object, preserving their order.
+func (rules Rules) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	for i, rule := range rules {
+		if i != 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(rule.Name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		val, err := json.Marshal(rule.Rule)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(val)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// Rule is a Tree-sitter rule definition.
+type Rule struct {
+	// Rule type (e.g. SEQ, CHOICE, REPEAT, STRING, PATTERN, SYMBOL).
+	Type string `json:"type"`
+	// Symbol name of SYMBOL rules.
+	Name string `json:"name,omitempty"`
+	// String value of STRING and PATTERN rules, or precedence of PREC rules.
+	Value interface{} `json:"value,omitempty"`
+	// Members of SEQ and CHOICE rules.
+	Members []*Rule `json:"members,omitempty"`
+	// Content of REPEAT, REPEAT1, TOKEN and PREC rules.
+	Content *Rule `json:"content,omitempty"`
+}
+
+// convert converts the given EBNF grammar to a Tree-sitter grammar, with the
+// start production as start rule and the skip production as extras.
+func convert(grammar ebnf.Grammar, name, start, skipRule string) (*Grammar, error) {
+	if analysis.IsLexical(start) {
+		return nil, errors.Errorf("invalid start production %q; start production must be a syntactic production (uppercase name)", start)
+	}
+	c := &converter{
+		grammar:  grammar,
+		used:     make(map[string]bool),
+		prec:     make(map[string]int),
+		nullable: analysis.Nullable(grammar),
+	}
+	prods := format.Prods(grammar)
+	var lexical []string
+	for _, prod := range prods {
+		if analysis.IsLexical(prod.Name.String) {
+			lexical = append(lexical, prod.Name.String)
+		}
+	}
+	// Earlier lexical productions take precedence over later ones, and
+	// literal tokens (of implicit precedence 0) over lexical productions.
+	for i, name := range lexical {
+		c.prec[name] = -(i + 1)
+	}
+	g := &Grammar{
+		Name:       name,
+		Extras:     []*Rule{},
+		Conflicts:  [][]string{},
+		Externals:  []*Rule{},
+		Inline:     []string{},
+		Supertypes: []string{},
+	}
+	// Syntactic production rules, starting with the start production.
+	syntactic := []*ebnf.Production{grammar[start]}
+	for _, prod := range prods {
+		if prod.Name.String != start && !analysis.IsLexical(prod.Name.String) {
+			syntactic = append(syntactic, prod)
+		}
+	}
+	for _, prod := range syntactic {
+		rule, err := c.expr(prod.Expr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		g.Rules = append(g.Rules, &NamedRule{Name: prod.Name.String, Rule: rule})
+	}
+	// Skip production rule.
+	if _, ok := grammar[skipRule]; ok {
+		if !analysis.IsLexical(skipRule) {
+			return nil, errors.Errorf("invalid skip rule %q; skip rule must be a lexical production (lowercase name)", skipRule)
+		}
+		c.used[skipRule] = true
+		g.Extras = append(g.Extras, symbol(skipRule))
+	} else {
+		g.Extras = append(g.Extras, &Rule{Type: "PATTERN", Value: `\s`})
+	}
+	// Lexical production rules referenced from syntactic production rules.
+	for _, name := range lexical {
+		if !c.used[name] {
+			continue
+		}
+		rule, err := c.token(name, name == skipRule)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		g.Rules = append(g.Rules, &NamedRule{Name: name, Rule: rule})
+	}
+	return g, nil
+}
+
+// converter converts EBNF production rules to Tree-sitter rules.
+type converter struct {
+	// EBNF grammar.
+	grammar ebnf.Grammar
+	// Lexical productions referenced from syntactic productions.
+	used map[string]bool
+	// Lexical precedence of lexical productions.
+	prec map[string]int
+	// Nullable productions.
+	nullable map[string]bool
+}
+
+// expr returns the Tree-sitter rule of the given expression of a syntactic
+// production rule.
+func (c *converter) expr(x ebnf.Expression) (*Rule, error) {
+	switch x := x.(type) {
+	case *ebnf.Name:
+		if _, ok := c.grammar[x.String]; !ok {
+			return nil, errors.Errorf("%v: missing production %q", x.Pos(), x.String)
+		}
+		if analysis.IsLexical(x.String) {
+			c.used[x.String] = true
+		}
+		return symbol(x.String), nil
+	default:
+		return c.common(x, c.expr)
+	}
+}
+
+// token returns the token(prec(N, ...)) rule of the given lexical production
+// rule, with referenced lexical production rules inlined. Tokens used as extras
+// may not match the empty string, and a repetition at the top level of the
+// production is therefore converted to repeat1.
+func (c *converter) token(name string, extra bool) (*Rule, error) {
+	prod := c.grammar[name]
+	if c.nullable[name] && !extra {
+		warn.Printf("lexical production %q matches the empty string, which is not supported by Tree-sitter", name)
+	}
+	l := &lexer{converter: c, visiting: map[string]bool{name: true}}
+	var content *Rule
+	var err error
+	if rep, ok := prod.Expr.(*ebnf.Repetition); ok && extra {
+		var body *Rule
+		if body, err = l.expr(rep.Body); err == nil {
+			content = &Rule{Type: "REPEAT1", Content: body}
+		}
+	} else {
+		content, err = l.expr(prod.Expr)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	prec := &Rule{Type: "PREC", Value: c.prec[name], Content: content}
+	return &Rule{Type: "TOKEN", Content: prec}, nil
+}
+
+// lexer converts lexical production rules to Tree-sitter token contents.
+type lexer struct {
+	*converter
+	// Lexical productions being inlined, to detect recursion.
+	visiting map[string]bool
+}
+
+// expr returns the Tree-sitter rule of the given expression of a lexical
+// production rule, with referenced production rules inlined.
+func (l *lexer) expr(x ebnf.Expression) (*Rule, error) {
+	switch x := x.(type) {
+	case *ebnf.Name:
+		prod, ok := l.grammar[x.String]
+		if !ok {
+			return nil, errors.Errorf("%v: missing production %q", x.Pos(), x.String)
+		}
+		if !analysis.IsLexical(x.String) {
+			return nil, errors.Errorf("%v: reference to non-lexical production %q", x.Pos(), x.String)
+		}
+		if l.visiting[x.String] {
+			return nil, errors.Errorf("%v: unable to inline recursive lexical production %q", x.Pos(), x.String)
+		}
+		l.visiting[x.String] = true
+		defer delete(l.visiting, x.String)
+		return l.expr(prod.Expr)
+	default:
+		return l.common(x, l.expr)
+	}
+}
+
+// common returns the Tree-sitter rule of the given expression other than a
+// name, using f to convert subexpressions.
+func (c *converter) common(x ebnf.Expression, f func(x ebnf.Expression) (*Rule, error)) (*Rule, error) {
+	switch x := x.(type) {
+	case nil:
+		return blank(), nil
+	case ebnf.Alternative:
+		members, err := c.members(x, f)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &Rule{Type: "CHOICE", Members: members}, nil
+	case ebnf.Sequence:
+		members, err := c.members(x, f)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &Rule{Type: "SEQ", Members: members}, nil
+	case *ebnf.Token:
+		if len(x.String) == 0 {
+			return blank(), nil
+		}
+		return &Rule{Type: "STRING", Value: x.String}, nil
+	case *ebnf.Range:
+		pattern := fmt.Sprintf("[%s-%s]", classChar(x.Begin.String), classChar(x.End.String))
+		return &Rule{Type: "PATTERN", Value: pattern}, nil
+	case *ebnf.Group:
+		return f(x.Body)
+	case *ebnf.Option:
+		body, err := f(x.Body)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &Rule{Type: "CHOICE", Members: []*Rule{body, blank()}}, nil
+	case *ebnf.Repetition:
+		body, err := f(x.Body)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &Rule{Type: "REPEAT", Content: body}, nil
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// members returns the Tree-sitter rules of the given expressions, using f to
+// convert each expression.
+func (c *converter) members(xs []ebnf.Expression, f func(x ebnf.Expression) (*Rule, error)) ([]*Rule, error) {
+	var members []*Rule
+	for _, x := range xs {
+		member, err := f(x)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// symbol returns a SYMBOL rule referencing the given rule.
+func symbol(name string) *Rule {
+	return &Rule{Type: "SYMBOL", Name: name}
+}
+
+// blank returns a BLANK rule, matching the empty string.
+func blank() *Rule {
+	return &Rule{Type: "BLANK"}
+}
+
+// classChar returns the given character escaped for use in a character class
+// of a Tree-sitter pattern.
+func classChar(s string) string {
+	r, _ := utf8.DecodeRuneInString(s)
+	switch {
+	case r == '\\', r == ']', r == '[', r == '^', r == '-':
+		return `\` + string(r)
+	case !unicode.IsPrint(r) || r == ' ':
+		if r > 0xFFFF {
+			return fmt.Sprintf(`\u{%X}`, r)
+		}
+		return fmt.Sprintf(`\u%04X`, r)
+	}
+	return string(r)
+}
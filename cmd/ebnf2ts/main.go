@@ -0,0 +1,298 @@
+// The ebnf2ts tool converts EBNF grammars to TypeScript type declarations.
+//
+// Syntactic productions are converted to declarations, where sequences map to
+// interfaces with one field per referenced production, alternatives to union
+// types, repetitions to arrays, options to union types with undefined, and
+// tokens to string literal types. Tokens of sequences are considered
+// punctuation and are omitted. Productions referenced within repetitions of
+// sequences map to array fields. References to lexical productions map to
+// string.
+//
+//	Entry = Key "=" Value { "," Value } .
+//	Value = string | number | "true" | "false" .
+//
+// is converted to
+//
+//	export interface Entry {
+//	    key: Key;
+//	    value: Value;
+//	    values: Value[];
+//	}
+//
+//	export type Value = string | "true" | "false";
+//
+// The output is a TypeScript declaration file (.d.ts).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+var (
+	// warn is a logger with the "ebnf2ts:" prefix which logs warning messages
+	// to standard error.
+	warn = log.New(os.Stderr, term.RedBold("ebnf2ts:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Usage: ebnf2ts [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+	)
+	flag.StringVar(&output, "o", "grammar.d.ts", "output path")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Convert grammar to TypeScript type declarations.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	decls := tsDecls(filepath.Base(grammarPath), grammar)
+	if err := ioutil.WriteFile(output, []byte(decls), 0644); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// tsDecls returns the TypeScript type declarations of the syntactic
+// productions of the given grammar.
+func tsDecls(grammarName string, grammar ebnf.Grammar) string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "// Code generated by ebnf2ts from %q; DO NOT EDIT.\n", grammarName)
+	for _, name := range analysis.Names(grammar) {
+		if analysis.IsLexical(name) {
+			continue
+		}
+		buf.WriteString("\n")
+		buf.WriteString(prodDecl(grammar[name]))
+	}
+	return buf.String()
+}
+
+// prodDecl returns the TypeScript type declaration of the given syntactic
+// production.
+func prodDecl(prod *ebnf.Production) string {
+	name := prod.Name.String
+	switch x := prod.Expr.(type) {
+	case nil:
+		return fmt.Sprintf("export type %s = null;\n", name)
+	case ebnf.Sequence:
+		buf := &strings.Builder{}
+		fmt.Fprintf(buf, "export interface %s {\n", name)
+		for _, f := range fields(name, x) {
+			fmt.Fprintf(buf, "\t%s: %s;\n", f.name, f.typ)
+		}
+		buf.WriteString("}\n")
+		return buf.String()
+	default:
+		return fmt.Sprintf("export type %s = %s;\n", name, exprType(name, x))
+	}
+}
+
+// field is a field of a TypeScript interface or object type.
+type field struct {
+	// Field name.
+	name string
+	// Field type.
+	typ string
+}
+
+// fields returns the fields of the given sequence, with one field per
+// referenced production. Tokens of the sequence are considered punctuation and
+// are omitted. Productions referenced within repetitions map to array fields
+// with plural field names (e.g. terms: Term[]), and productions referenced
+// within options or alternatives map to union types with undefined. Repeated
+// references within the same sequence are numbered (e.g. value2).
+func fields(prodName string, seq ebnf.Sequence) []field {
+	var fs []field
+	uses := make(map[string]int)
+	add := func(fname, typ string) {
+		uses[fname]++
+		if n := uses[fname]; n > 1 {
+			fname = fmt.Sprintf("%s%d", fname, n)
+		}
+		fs = append(fs, field{name: fname, typ: typ})
+	}
+	// walk adds the fields of productions referenced within the given
+	// expression, where rep reports whether the expression is within a
+	// repetition, and opt whether the expression is within an option or
+	// alternative.
+	var walk func(x ebnf.Expression, rep, opt bool)
+	walk = func(x ebnf.Expression, rep, opt bool) {
+		switch x := x.(type) {
+		case nil:
+			// empty expression.
+		case *ebnf.Token:
+			// punctuation.
+		case *ebnf.Name:
+			switch {
+			case rep:
+				add(pluralName(fieldName(x.String)), nameType(x.String)+"[]")
+			case opt:
+				add(fieldName(x.String), nameType(x.String)+" | undefined")
+			default:
+				add(fieldName(x.String), nameType(x.String))
+			}
+		case ebnf.Sequence:
+			for _, e := range x {
+				walk(e, rep, opt)
+			}
+		case ebnf.Alternative:
+			for _, e := range x {
+				walk(e, rep, true)
+			}
+		case *ebnf.Group:
+			walk(x.Body, rep, opt)
+		case *ebnf.Option:
+			walk(x.Body, rep, true)
+		case *ebnf.Repetition:
+			walk(x.Body, true, opt)
+		default:
+			warn.Printf("%v: expression %v in production %q has no field name; omitted", x.Pos(), format.SprintExpr(x), prodName)
+		}
+	}
+	walk(seq, false, false)
+	return fs
+}
+
+// exprType returns the TypeScript type of the given expression of the
+// syntactic production.
+func exprType(prodName string, x ebnf.Expression) string {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		// omit duplicate types of union (e.g. of lexical productions).
+		var alts []string
+		seen := make(map[string]bool)
+		for _, e := range x {
+			typ := exprType(prodName, e)
+			if seen[typ] {
+				continue
+			}
+			seen[typ] = true
+			alts = append(alts, typ)
+		}
+		return strings.Join(alts, " | ")
+	case ebnf.Sequence:
+		var fs []string
+		for _, f := range fields(prodName, x) {
+			fs = append(fs, fmt.Sprintf("%s: %s", f.name, f.typ))
+		}
+		if len(fs) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(fs, "; ") + " }"
+	case *ebnf.Name:
+		return nameType(x.String)
+	case *ebnf.Token:
+		return quoteString(x.String)
+	case *ebnf.Range:
+		warn.Printf("%v: character range %v in production %q has no TypeScript equivalent; using string", x.Pos(), format.SprintExpr(x), prodName)
+		return "string"
+	case *ebnf.Group:
+		return exprType(prodName, x.Body)
+	case *ebnf.Option:
+		return exprType(prodName, x.Body) + " | undefined"
+	case *ebnf.Repetition:
+		typ := exprType(prodName, x.Body)
+		if strings.Contains(typ, "|") {
+			// parenthesize union types.
+			return "(" + typ + ")[]"
+		}
+		return typ + "[]"
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// nameType returns the TypeScript type of references to the given production;
+// string for lexical productions, and the production name otherwise.
+func nameType(name string) string {
+	if analysis.IsLexical(name) {
+		return "string"
+	}
+	return name
+}
+
+// fieldName returns the field name of references to the given production; the
+// production name with the first letter in lowercase.
+//
+//	Key    => key
+//	string => string
+func fieldName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// pluralName returns the plural form of the given field name, as used for
+// fields of repeated productions.
+//
+//	term  => terms
+//	class => classes
+//	entry => entries
+func pluralName(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	default:
+		return name + "s"
+	}
+}
+
+// quoteString returns the given TypeScript string literal in double quotes.
+func quoteString(s string) string {
+	buf := &strings.Builder{}
+	buf.WriteString(`"`)
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			buf.WriteString(`\` + string(r))
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteString(`"`)
+	return buf.String()
+}
@@ -0,0 +1,378 @@
+// The ebnf2yacc tool converts EBNF grammars to YACC (Bison) grammars.
+//
+// Syntactic production rules are converted to grammar rules, desugared to BNF
+// as by the ebnf2bnf tool. Lexical production rules referenced from syntactic
+// production rules are declared as tokens, and the output includes a %union
+// placeholder and a lexer stub.
+//
+// Single character tokens are written as character literals (e.g. '+'), and
+// other tokens are declared with a string alias (e.g. %token IF "if").
+//
+// Operator precedence is specified through grammar comments, which are copied
+// to the declarations section in order:
+//
+//	/* %left "+" "-" */
+//	/* %left "*" "/" */
+//
+// To keep operators visible to precedence declarations, grouped alternatives
+// of syntactic production rules are distributed over the enclosing sequence
+// rather than converted to new production rules.
+//
+//	x ( "+" | "-" ) y   =>   x "+" y | x "-" y
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/bnf"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+var (
+	// warn is a logger with the "ebnf2yacc:" prefix which logs warning messages
+	// to standard error.
+	warn = log.New(os.Stderr, term.RedBold("ebnf2yacc:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Usage: ebnf2yacc [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Start production rule.
+		start string
+	)
+	flag.StringVar(&output, "o", "", "output path (default stdout)")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Convert grammar to YACC.
+	src, err := ioutil.ReadFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	grammar, err := ebnf.Parse(grammarPath, bytes.NewReader(src))
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if _, ok := grammar[start]; !ok {
+		log.Fatalf("unable to locate start production rule %q in grammar %q", start, grammarPath)
+	}
+	w := os.Stdout
+	if len(output) > 0 {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	for _, prod := range grammar {
+		if !analysis.IsLexical(prod.Name.String) {
+			prod.Expr = distribute(prod.Expr)
+		}
+	}
+	c := newConverter(grammar)
+	c.writeYacc(bw, grammarPath, src, start)
+	if err := bw.Flush(); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// converter converts EBNF grammars to YACC.
+type converter struct {
+	// EBNF grammar.
+	grammar ebnf.Grammar
+	// Names in use by production rules and tokens.
+	used map[string]bool
+	// Token names of multi-character tokens, indexed by token string.
+	tokenNames map[string]string
+	// Declared tokens, in order of occurrence.
+	tokens []string
+}
+
+// newConverter returns a new converter for the given EBNF grammar.
+func newConverter(grammar ebnf.Grammar) *converter {
+	used := make(map[string]bool)
+	for name := range grammar {
+		used[name] = true
+	}
+	return &converter{
+		grammar:    grammar,
+		used:       used,
+		tokenNames: make(map[string]string),
+	}
+}
+
+// reComment matches grammar comments.
+var reComment = regexp.MustCompile(`(?s)/\*(.*?)\*/`)
+
+// rePrec matches operator precedence declarations in grammar comments.
+var rePrec = regexp.MustCompile(`^\s*(%left|%right|%nonassoc|%precedence)\s+(.*?)\s*$`)
+
+// reSymbol matches symbols of operator precedence declarations.
+var reSymbol = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|[A-Za-z_][A-Za-z0-9_]*`)
+
+// writeYacc writes the YACC grammar of the given EBNF grammar to w.
+func (c *converter) writeYacc(w io.Writer, grammarPath string, src []byte, start string) {
+	// Convert syntactic production rules; this collects the declared tokens.
+	rules := &bytes.Buffer{}
+	var lexical []string
+	for _, prod := range bnf.Convert(c.grammar) {
+		if analysis.IsLexical(prod.Name) {
+			continue
+		}
+		c.writeRule(rules, prod)
+	}
+	// Operator precedence declarations.
+	var precs []string
+	for _, m := range reComment.FindAllSubmatch(src, -1) {
+		pm := rePrec.FindStringSubmatch(string(m[1]))
+		if pm == nil {
+			continue
+		}
+		prec := pm[1]
+		for _, sym := range reSymbol.FindAllString(pm[2], -1) {
+			if s, err := strconv.Unquote(sym); err == nil {
+				sym = c.literal(s)
+			}
+			prec += " " + sym
+		}
+		precs = append(precs, prec)
+	}
+	for _, name := range c.tokens {
+		if _, ok := c.grammar[name]; ok {
+			lexical = append(lexical, name)
+		}
+	}
+
+	// Declarations.
+	fmt.Fprintf(w, "/* Generated by ebnf2yacc from %s. */\n\n", grammarPath)
+	fmt.Fprintln(w, "%{\n%}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "%union {\n\t/* TODO: add semantic value types. */\n}")
+	fmt.Fprintln(w)
+	for _, tok := range c.tokens {
+		if _, ok := c.grammar[tok]; ok {
+			fmt.Fprintf(w, "%%token %s\n", tok)
+			continue
+		}
+		fmt.Fprintf(w, "%%token %s %s\n", tok, strconv.Quote(c.tokenString(tok)))
+	}
+	if len(c.tokens) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, prec := range precs {
+		fmt.Fprintln(w, prec)
+	}
+	if len(precs) > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%%start %s\n\n", start)
+
+	// Grammar rules.
+	fmt.Fprintln(w, "%%")
+	fmt.Fprintln(w)
+	rules.WriteTo(w)
+	fmt.Fprintln(w, "%%")
+	fmt.Fprintln(w)
+
+	// Lexer stub.
+	fmt.Fprintln(w, "/* Lexer stub, recognizing the tokens of the following lexical production")
+	fmt.Fprintln(w, " * rules of the grammar:")
+	fmt.Fprintln(w, " *")
+	for _, name := range lexical {
+		fmt.Fprintf(w, " *    %s\n", format.SprintProd(c.grammar[name]))
+	}
+	fmt.Fprintln(w, " */")
+	fmt.Fprintln(w, "int yylex(void) {\n\t/* TODO: implement lexer. */\n\treturn 0;\n}")
+}
+
+// writeRule writes the given BNF production rule as a YACC grammar rule to w.
+func (c *converter) writeRule(w io.Writer, prod *bnf.Production) {
+	fmt.Fprintln(w, prod.Name)
+	for i, alt := range prod.Alts {
+		sep := "|"
+		if i == 0 {
+			sep = ":"
+		}
+		fmt.Fprintf(w, "\t%s", sep)
+		var syms []string
+		for _, e := range alt {
+			if bnf.IsEmpty(e) {
+				continue
+			}
+			syms = append(syms, c.symbol(prod.Name, e))
+		}
+		if len(syms) == 0 {
+			syms = append(syms, "/* empty */")
+		}
+		fmt.Fprintf(w, " %s\n", strings.Join(syms, " "))
+	}
+	if len(prod.Alts) == 0 {
+		fmt.Fprintln(w, "\t: /* empty */")
+	}
+	fmt.Fprintln(w, "\t;")
+	fmt.Fprintln(w)
+}
+
+// symbol returns the YACC symbol of the given name, token or range of a BNF
+// alternative.
+func (c *converter) symbol(prodName string, x ebnf.Expression) string {
+	switch x := x.(type) {
+	case *ebnf.Name:
+		if analysis.IsLexical(x.String) {
+			c.declare(x.String)
+		}
+		return x.String
+	case *ebnf.Token:
+		return c.literal(x.String)
+	case *ebnf.Range:
+		warn.Printf("%v: character range %v in syntactic production %q has no YACC equivalent; move to a lexical production", x.Pos(), format.SprintExpr(x), prodName)
+		return fmt.Sprintf("/* %s */", format.SprintExpr(x))
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// literal returns the YACC symbol of the given token; a character literal for
+// single ASCII characters, and a string alias of a declared token otherwise.
+func (c *converter) literal(s string) string {
+	if len(s) == 1 && s[0] >= ' ' && s[0] <= '~' {
+		switch s {
+		case `'`, `\`:
+			return `'\` + s + `'`
+		}
+		return "'" + s + "'"
+	}
+	if _, ok := c.tokenNames[s]; !ok {
+		name := c.tokenName(s)
+		c.tokenNames[s] = name
+		c.declare(name)
+	}
+	return strconv.Quote(s)
+}
+
+// reIdent matches identifiers.
+var reIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// tokenName returns an unused token name for the given multi-character token;
+// the uppercase token for keywords (e.g. IF for "if"), and TOKEN_N otherwise.
+func (c *converter) tokenName(s string) string {
+	base := fmt.Sprintf("TOKEN_%d", len(c.tokenNames)+1)
+	if reIdent.MatchString(s) && utf8.ValidString(s) {
+		base = strings.ToUpper(s)
+	}
+	name := base
+	for i := 2; c.used[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	c.used[name] = true
+	return name
+}
+
+// tokenString returns the token string of the given declared token name.
+func (c *converter) tokenString(name string) string {
+	for s, n := range c.tokenNames {
+		if n == name {
+			return s
+		}
+	}
+	return ""
+}
+
+// declare declares the given token, if not already declared.
+func (c *converter) declare(name string) {
+	for _, tok := range c.tokens {
+		if tok == name {
+			return
+		}
+	}
+	c.tokens = append(c.tokens, name)
+}
+
+// distribute distributes the grouped alternatives of sequences within the given
+// expression over the sequence.
+//
+//	x ( y | z )   =>   x y | x z
+func distribute(x ebnf.Expression) ebnf.Expression {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		var alts ebnf.Alternative
+		for _, e := range x {
+			alts = append(alts, distribute(e))
+		}
+		return alts
+	case ebnf.Sequence:
+		// Cross product of the choices of each element.
+		seqs := []ebnf.Sequence{nil}
+		for _, e := range x {
+			e = distribute(e)
+			choices := []ebnf.Expression{e}
+			if g, ok := e.(*ebnf.Group); ok {
+				if alt, ok := g.Body.(ebnf.Alternative); ok {
+					choices = alt
+				}
+			}
+			var next []ebnf.Sequence
+			for _, seq := range seqs {
+				for _, choice := range choices {
+					s := append(ebnf.Sequence{}, seq...)
+					next = append(next, append(s, choice))
+				}
+			}
+			seqs = next
+		}
+		if len(seqs) == 1 {
+			return seqs[0]
+		}
+		var alts ebnf.Alternative
+		for _, seq := range seqs {
+			alts = append(alts, seq)
+		}
+		return alts
+	case *ebnf.Group:
+		return &ebnf.Group{Lparen: x.Lparen, Body: distribute(x.Body)}
+	case *ebnf.Option:
+		return &ebnf.Option{Lbrack: x.Lbrack, Body: distribute(x.Body)}
+	case *ebnf.Repetition:
+		return &ebnf.Repetition{Lbrace: x.Lbrace, Body: distribute(x.Body)}
+	default:
+		// nil, *ebnf.Name, *ebnf.Token, *ebnf.Range and *ebnf.Bad.
+		return x
+	}
+}
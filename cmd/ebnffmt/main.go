@@ -0,0 +1,114 @@
+// The ebnffmt tool formats EBNF grammars.
+//
+// Comments are not preserved. To prevent loss of comments and directives (e.g.
+// include directives and doc comments), grammars containing comments are not
+// formatted in place with -w.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/scanner"
+
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: ebnffmt [OPTION]... FILE...
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Write result to source file instead of standard output.
+		write bool
+		// Align "=" signs of production rules.
+		align bool
+		// Maximum length of production names used for alignment.
+		alignMax int
+	)
+	flag.BoolVar(&write, "w", false, "write result to source file instead of standard output (refused for grammars containing comments)")
+	flag.BoolVar(&align, "align", false, "align \"=\" signs of production rules to the longest production name")
+	flag.IntVar(&alignMax, "align-max", 0, "maximum length of production names used for alignment; bodies of longer names are wrapped onto the next line (0 disables limit)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Format grammars.
+	for _, grammarPath := range flag.Args() {
+		if err := formatGrammar(grammarPath, write, align, alignMax); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+}
+
+// formatGrammar formats the given EBNF grammar, writing the result to standard
+// output or back to the source file. If align is set, the "=" signs of
+// production rules are aligned to the longest production name of at most
+// alignMax characters.
+func formatGrammar(grammarPath string, write, align bool, alignMax int) error {
+	src, err := ioutil.ReadFile(grammarPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if write && hasComments(grammarPath, src) {
+		return errors.Errorf("unable to format %q in place; grammar contains comments or directives which would be lost", grammarPath)
+	}
+	grammar, err := ebnf.Parse(grammarPath, bytes.NewReader(src))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf := &bytes.Buffer{}
+	if align {
+		if err := format.FprintAligned(buf, grammar, alignMax); err != nil {
+			return errors.WithStack(err)
+		}
+	} else if err := format.Fprint(buf, grammar); err != nil {
+		return errors.WithStack(err)
+	}
+	if !write {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+	fi, err := os.Stat(grammarPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ioutil.WriteFile(grammarPath, buf.Bytes(), fi.Mode()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// hasComments reports whether the given source of an EBNF grammar contains
+// comments, including directives embedded in comments.
+func hasComments(grammarPath string, src []byte) bool {
+	var s scanner.Scanner
+	s.Init(bytes.NewReader(src))
+	s.Filename = grammarPath
+	s.Mode = scanner.GoTokens &^ scanner.SkipComments
+	// Syntax errors are reported by the EBNF parser.
+	s.Error = func(s *scanner.Scanner, msg string) {}
+	for tok := s.Scan(); tok != scanner.EOF; tok = s.Scan() {
+		if tok == scanner.Comment {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+// The ebnfhl tool highlights the syntax of EBNF grammars for terminal output.
+//
+// Production names are highlighted in cyan (bold where defined), tokens in
+// green, character ranges in yellow, alternatives in magenta, brackets in white
+// and comments in dark gray. The source text is otherwise preserved.
+//
+// Grammars are tokenized using text/scanner as configured by the EBNF parser of
+// golang.org/x/exp/ebnf, but with comments retained.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/scanner"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Usage: ebnfhl [OPTION]... FILE...
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Disable colors.
+		noColor bool
+	)
+	flag.BoolVar(&noColor, "no-color", false, "disable colors (e.g. when piping output)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Highlight grammars.
+	bw := bufio.NewWriter(os.Stdout)
+	defer bw.Flush()
+	for _, grammarPath := range flag.Args() {
+		src, err := ioutil.ReadFile(grammarPath)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		if err := highlight(bw, grammarPath, src, !noColor); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+}
+
+// token is a lexical token of an EBNF grammar.
+type token struct {
+	// Token kind, as returned by text/scanner.
+	kind rune
+	// Start and end offset of the token in the grammar source.
+	start, end int
+}
+
+// highlight writes the given EBNF grammar source to w, with syntax highlighting
+// if color is set.
+func highlight(w io.Writer, grammarPath string, src []byte, color bool) error {
+	tokens, err := tokenize(grammarPath, src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf := &bytes.Buffer{}
+	prev := 0
+	for i, tok := range tokens {
+		// whitespace between tokens.
+		buf.Write(src[prev:tok.start])
+		prev = tok.end
+		text := string(src[tok.start:tok.end])
+		if !color {
+			buf.WriteString(text)
+			continue
+		}
+		buf.WriteString(colorize(tokens, i, text))
+	}
+	buf.Write(src[prev:])
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Color code of dark gray text.
+const fgDarkGray = "90"
+
+// colorize returns the text of the i:th token colored based on its kind and
+// surrounding tokens.
+func colorize(tokens []token, i int, text string) string {
+	switch tok := tokens[i]; tok.kind {
+	case scanner.Comment:
+		return term.Color(text, fgDarkGray)
+	case scanner.Ident:
+		if neighbor(tokens, i, 1) == '=' {
+			// production definition.
+			return term.CyanBold(text)
+		}
+		return term.Cyan(text)
+	case scanner.String, scanner.RawString, scanner.Char:
+		if neighbor(tokens, i, 1) == '…' || neighbor(tokens, i, -1) == '…' {
+			return term.Yellow(text)
+		}
+		return term.Green(text)
+	case '…':
+		return term.Yellow(text)
+	case '|':
+		return term.Magenta(text)
+	case '(', ')', '[', ']', '{', '}':
+		return term.White(text)
+	default:
+		return text
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// tokenize returns the lexical tokens of the given EBNF grammar source.
+func tokenize(grammarPath string, src []byte) ([]token, error) {
+	var errs []string
+	var s scanner.Scanner
+	s.Init(bytes.NewReader(src))
+	s.Filename = grammarPath
+	s.Mode = scanner.GoTokens &^ scanner.SkipComments
+	s.Error = func(s *scanner.Scanner, msg string) {
+		errs = append(errs, fmt.Sprintf("%v: %s", s.Pos(), msg))
+	}
+	var tokens []token
+	for kind := s.Scan(); kind != scanner.EOF; kind = s.Scan() {
+		start := s.Position.Offset
+		tokens = append(tokens, token{kind: kind, start: start, end: start + len(s.TokenText())})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Errorf("unable to tokenize grammar; %s", errs[0])
+	}
+	return tokens, nil
+}
+
+// neighbor returns the kind of the closest token after (dir = 1) or before
+// (dir = -1) the i:th token, ignoring comments; or scanner.EOF if not present.
+func neighbor(tokens []token, i, dir int) rune {
+	for j := i + dir; j >= 0 && j < len(tokens); j += dir {
+		if tokens[j].kind != scanner.Comment {
+			return tokens[j].kind
+		}
+	}
+	return scanner.EOF
+}
@@ -0,0 +1,201 @@
+// The ebnflint tool reports problems of EBNF grammars.
+//
+// The following checks are performed:
+//
+//   - syntax: the grammar is syntactically valid
+//   - verify: productions used are defined, and lexical productions only refer
+//     to lexical productions
+//   - unreachable: productions are reachable from the start production
+//   - left-recursion: productions are not left-recursive
+//   - dead-alternative: alternatives are not shadowed by earlier alternatives
+//     which are identical to them or to a prefix of them
+//   - possibly-dead-alternative: FIRST sets of alternatives are not covered by
+//     the FIRST sets of earlier alternatives (heuristic)
+//
+// Problems are reported in text format, or with -sarif in SARIF format for use
+// with code scanning tools.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/scanner"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: ebnflint [OPTION]... FILE...
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output problems in SARIF format.
+		sarif bool
+		// Start production rule.
+		start string
+		// Skip production rule.
+		skipRule string
+	)
+	flag.BoolVar(&sarif, "sarif", false, "output problems in SARIF format to standard output")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Lint grammars.
+	var problems []*problem
+	for _, grammarPath := range flag.Args() {
+		ps, err := lint(grammarPath, start, skipRule)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		problems = append(problems, ps...)
+	}
+	if sarif {
+		if err := printSARIF(os.Stdout, problems); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	} else {
+		for _, p := range problems {
+			fmt.Printf("%v: %s: %s (%s)\n", p.pos, p.level, p.msg, p.rule)
+		}
+	}
+	for _, p := range problems {
+		if p.level == levelError {
+			os.Exit(1)
+		}
+	}
+}
+
+// Problem levels.
+const (
+	levelError   = "error"
+	levelWarning = "warning"
+)
+
+// problem is a problem of an EBNF grammar.
+type problem struct {
+	// Check reporting the problem (syntax, verify, unreachable, left-recursion,
+	// dead-alternative or possibly-dead-alternative).
+	rule string
+	// Problem level (error or warning).
+	level string
+	// Position of the problem in the grammar.
+	pos scanner.Position
+	// Problem description.
+	msg string
+}
+
+// lint returns the problems of the given EBNF grammar.
+func lint(grammarPath, start, skipRule string) ([]*problem, error) {
+	src, err := ioutil.ReadFile(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	grammar, err := ebnf.Parse(grammarPath, bytes.NewReader(src))
+	if err != nil {
+		var problems []*problem
+		for _, e := range analysis.SplitErrors(err) {
+			pos, msg := splitPos(grammarPath, src, e)
+			problems = append(problems, &problem{rule: "syntax", level: levelError, pos: pos, msg: msg})
+		}
+		return problems, nil
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	var problems []*problem
+	if err := analysis.Verify(grammar, skipRule, start); err != nil {
+		for _, e := range analysis.SplitErrors(err) {
+			pos, msg := splitPos(grammarPath, src, e)
+			p := &problem{rule: "verify", level: levelError, pos: pos, msg: msg}
+			if name := strings.TrimSuffix(msg, " is unreachable"); name != msg {
+				p.rule = "unreachable"
+				p.level = levelWarning
+				p.msg = fmt.Sprintf("production %s is unreachable from start production %s", name, start)
+			}
+			problems = append(problems, p)
+		}
+	}
+	for _, cycle := range analysis.DetectLeftRecursion(grammar) {
+		prod := grammar[cycle[0]]
+		msg := fmt.Sprintf("left-recursive cycle %s", strings.Join(append(cycle, cycle[0]), " -> "))
+		problems = append(problems, &problem{rule: "left-recursion", level: levelWarning, pos: prod.Pos(), msg: msg})
+	}
+	_, diags := analysis.RemoveDeadAlternatives(grammar)
+	for _, d := range diags {
+		if !d.Proven {
+			msg := fmt.Sprintf("alternative %s of production %s may be shadowed by an earlier alternative beginning with the same input", format.SprintExpr(d.Alt), d.Production)
+			problems = append(problems, &problem{rule: "possibly-dead-alternative", level: levelWarning, pos: d.Alt.Pos(), msg: msg})
+			continue
+		}
+		msg := fmt.Sprintf("alternative %s of production %s is shadowed by an earlier alternative", format.SprintExpr(d.Alt), d.Production)
+		problems = append(problems, &problem{rule: "dead-alternative", level: levelWarning, pos: d.Alt.Pos(), msg: msg})
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].pos.Offset < problems[j].pos.Offset
+	})
+	return problems, nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// reError matches error messages with a position prefix, as produced by the
+// ebnf package (e.g. "foo.ebnf:3:7: msg").
+var reError = regexp.MustCompile(`^(?:[^:]*:)?([0-9]+):([0-9]+): (.*)$`)
+
+// splitPos splits the given error of the ebnf package into position and
+// message, resolving the byte offset of the position in the grammar source.
+func splitPos(grammarPath string, src []byte, err error) (scanner.Position, string) {
+	pos := scanner.Position{Filename: grammarPath}
+	msg := err.Error()
+	m := reError.FindStringSubmatch(msg)
+	if m == nil {
+		return pos, msg
+	}
+	pos.Line, _ = strconv.Atoi(m[1])
+	pos.Column, _ = strconv.Atoi(m[2])
+	pos.Offset = offsetOf(src, pos.Line, pos.Column)
+	return pos, m[3]
+}
+
+// offsetOf returns the byte offset of the given 1-based line and column (in
+// characters) in src.
+func offsetOf(src []byte, line, col int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		n := bytes.IndexByte(src[offset:], '\n')
+		if n == -1 {
+			return offset
+		}
+		offset += n + 1
+	}
+	for i := 1; i < col && offset < len(src); i++ {
+		_, size := utf8.DecodeRune(src[offset:])
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// sarifLog is a Static Analysis Results Interchange Format (SARIF) log.
+//
+// ref: https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarifRun is a run of an analysis tool.
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+// sarifTool is an analysis tool.
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+// sarifDriver is the component of an analysis tool which runs the analysis.
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+// sarifRule is a check performed by an analysis tool.
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+// sarifResult is a problem reported by an analysis tool.
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+// sarifMessage is a message of a SARIF log.
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifLocation is a location of a problem.
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+// sarifPhysicalLocation is a location within a file.
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+// sarifArtifactLocation is the location of a file.
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRegion is a region within a file. Lines and columns are 1-based.
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	ByteOffset  int `json:"byteOffset"`
+}
+
+// rules lists the checks performed by ebnflint.
+var rules = []sarifRule{
+	{ID: "syntax", ShortDescription: sarifMessage{Text: "grammar is syntactically valid"}},
+	{ID: "verify", ShortDescription: sarifMessage{Text: "productions used are defined, and lexical productions only refer to lexical productions"}},
+	{ID: "unreachable", ShortDescription: sarifMessage{Text: "productions are reachable from the start production"}},
+	{ID: "left-recursion", ShortDescription: sarifMessage{Text: "productions are not left-recursive"}},
+	{ID: "dead-alternative", ShortDescription: sarifMessage{Text: "alternatives are not shadowed by earlier alternatives"}},
+	{ID: "possibly-dead-alternative", ShortDescription: sarifMessage{Text: "FIRST sets of alternatives are not covered by earlier alternatives"}},
+}
+
+// printSARIF prints the given problems in SARIF format to w.
+func printSARIF(w io.Writer, problems []*problem) error {
+	results := make([]sarifResult, 0, len(problems))
+	for _, p := range problems {
+		result := sarifResult{
+			RuleID:  p.rule,
+			Level:   p.level,
+			Message: sarifMessage{Text: p.msg},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: p.pos.Filename},
+					Region: sarifRegion{
+						StartLine:   p.pos.Line,
+						StartColumn: p.pos.Column,
+						ByteOffset:  p.pos.Offset,
+					},
+				},
+			}},
+		}
+		results = append(results, result)
+	}
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           "ebnflint",
+					InformationURI: "https://github.com/mewmew/speak",
+					Rules:          rules,
+				},
+			},
+			Results: results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(log); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// The ebnfmv tool renames production rules of EBNF grammars.
+//
+// The production rule is renamed and every reference to it is updated. The
+// result is written in place, keeping a backup of the original grammar, or to
+// the output path given by -o.
+//
+// Only the production names are rewritten in the source of the grammar; its
+// layout, comments and directives are preserved. Occurrences of the production
+// name within comments are left unchanged.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: ebnfmv [OPTION]... FILE FROM TO
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Suffix of backup file when renaming in place.
+		backupSuffix string
+	)
+	flag.StringVar(&output, "o", "", "output path (default rename in place)")
+	flag.StringVar(&backupSuffix, "backup", ".bak", "suffix of backup file when renaming in place; empty disables backup")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath, from, to := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+
+	// Rename production rule.
+	src, err := ioutil.ReadFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	grammar, err := ebnf.Parse(grammarPath, bytes.NewReader(src))
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	buf, err := rename(grammar, src, from, to)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	fi, err := os.Stat(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	if len(output) == 0 {
+		output = grammarPath
+		if len(backupSuffix) > 0 {
+			if err := os.Rename(grammarPath, grammarPath+backupSuffix); err != nil {
+				log.Fatalf("%+v", errors.WithStack(err))
+			}
+		}
+	}
+	if err := ioutil.WriteFile(output, buf, fi.Mode()); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// rename renames the production from to the name to in the given source of the
+// grammar, and updates every reference to the production. The updated source is
+// returned.
+func rename(grammar ebnf.Grammar, src []byte, from, to string) ([]byte, error) {
+	prod, ok := grammar[from]
+	if !ok {
+		return nil, errors.Errorf("unable to locate production rule %q", from)
+	}
+	if _, ok := grammar[to]; ok {
+		return nil, errors.Errorf("production rule %q already present in grammar", to)
+	}
+	if analysis.IsLexical(from) != analysis.IsLexical(to) {
+		return nil, errors.Errorf("unable to rename production rule %q to %q; renaming changes between lexical and syntactic production", from, to)
+	}
+	// Source offsets of the production name and its references.
+	names := []*ebnf.Name{prod.Name}
+	for _, site := range analysis.UsageSites(grammar)[from] {
+		names = append(names, site.Name)
+	}
+	var offsets []int
+	for _, name := range names {
+		offset := name.Pos().Offset
+		if offset < 0 || offset+len(from) > len(src) || string(src[offset:offset+len(from)]) != from {
+			return nil, errors.Errorf("%v: unable to locate production name %q in source", name.Pos(), from)
+		}
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+	// Rewrite production names in source.
+	buf := &bytes.Buffer{}
+	prev := 0
+	for _, offset := range offsets {
+		buf.Write(src[prev:offset])
+		buf.WriteString(to)
+		prev = offset + len(from)
+	}
+	buf.Write(src[prev:])
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+// The firstfollow tool prints the FIRST and FOLLOW sets of EBNF grammars.
+//
+// The FIRST set of a production rule holds the tokens and character ranges
+// which may begin the production, and the FOLLOW set holds the tokens and
+// character ranges which may directly follow the production; EOF denotes end of
+// input.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: firstfollow [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output format (table or json).
+		outputFormat string
+		// Start production rule.
+		start string
+	)
+	flag.StringVar(&outputFormat, "format", "table", "output format (table or json)")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Compute FIRST and FOLLOW sets.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if _, ok := grammar[start]; !ok {
+		log.Fatalf("unable to locate start production rule %q in grammar %q", start, grammarPath)
+	}
+	sets := firstFollow(grammar, start)
+	switch outputFormat {
+	case "table":
+		err = printTable(sets)
+	case "json":
+		err = printJSON(sets)
+	default:
+		log.Fatalf("invalid output format %q; expected table or json", outputFormat)
+	}
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+}
+
+// Sets holds the FIRST and FOLLOW sets of a production rule.
+type Sets struct {
+	// Production name.
+	Name string `json:"name"`
+	// FIRST set of the production rule, sorted.
+	First []string `json:"first"`
+	// FOLLOW set of the production rule, sorted.
+	Follow []string `json:"follow"`
+}
+
+// firstFollow returns the FIRST and FOLLOW sets of the production rules of the
+// given grammar, in source order.
+func firstFollow(grammar ebnf.Grammar, start string) []*Sets {
+	first := analysis.First(grammar)
+	follow := analysis.Follow(grammar, start)
+	var sets []*Sets
+	for _, prod := range format.Prods(grammar) {
+		name := prod.Name.String
+		s := &Sets{
+			Name:   name,
+			First:  terminals(first[name]),
+			Follow: terminals(follow[name]),
+		}
+		sets = append(sets, s)
+	}
+	return sets
+}
+
+// terminals returns the sorted string representation of the terminals of the
+// given set.
+func terminals(set analysis.Set) []string {
+	ts := make([]string, 0, len(set))
+	for _, t := range set.Sorted() {
+		ts = append(ts, t.String())
+	}
+	return ts
+}
+
+// printTable prints the given FIRST and FOLLOW sets as a table to standard
+// output.
+func printTable(sets []*Sets) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "production\tFIRST\tFOLLOW")
+	for _, s := range sets {
+		fmt.Fprintf(w, "%s\t{%s}\t{%s}\n", s.Name, strings.Join(s.First, ", "), strings.Join(s.Follow, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// printJSON prints the given FIRST and FOLLOW sets in JSON format to standard
+// output.
+func printJSON(sets []*Sets) error {
+	buf, err := json.MarshalIndent(sets, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Println(string(buf))
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// Profile is the evaluation profile of a grammar on an input.
+type Profile struct {
+	// Per production statistics, keyed by production name.
+	Prods map[string]*ProdStats
+	// Total evaluation time.
+	Total time.Duration
+}
+
+// ProdStats holds the evaluation statistics of a production rule.
+type ProdStats struct {
+	// Number of times the production rule was evaluated.
+	Hits int
+	// Total evaluation time of the production rule, including nested production
+	// rules. Recursive evaluations are only accounted for once.
+	Time time.Duration
+}
+
+// profile evaluates the given input n times from the start production rule of
+// the grammar, using the skip production rule to ignore whitespace and
+// comments, and returns the accumulated evaluation profile.
+func profile(grammar ebnf.Grammar, start, skipRule string, input []byte, n int) (*Profile, error) {
+	prof := &Profile{
+		Prods: make(map[string]*ProdStats),
+	}
+	for name := range grammar {
+		prof.Prods[name] = &ProdStats{}
+	}
+	for i := 0; i < n; i++ {
+		p := &parser{
+			grammar:  grammar,
+			skipRule: skipRule,
+			input:    input,
+			prof:     prof,
+			active:   make(map[string]int),
+		}
+		begin := time.Now()
+		ok := p.evalProd(grammar[start])
+		p.skip()
+		prof.Total += time.Since(begin)
+		if !ok || p.pos != len(input) {
+			return nil, errors.Errorf("unable to parse input; syntax error at offset %d", p.pos)
+		}
+	}
+	return prof, nil
+}
+
+// parser is a profiling evaluator of EBNF grammars which records an evaluation
+// profile. It mirrors the ordered choice and skip semantics of the runtime
+// evaluator of the speak tool, without first-set pruning, maximum nesting depth
+// or parse tree construction.
+type parser struct {
+	// EBNF language grammar.
+	grammar ebnf.Grammar
+	// Name of skip production rule.
+	skipRule string
+	// Input source.
+	input []byte
+	// Current position in input source.
+	pos int
+	// Currently skipping whitespace and comments.
+	skipping bool
+	// Evaluation profile.
+	prof *Profile
+	// Number of active evaluations per production rule.
+	active map[string]int
+}
+
+// evalProd evaluates the given production rule, and records its hit count and
+// evaluation time.
+func (p *parser) evalProd(x *ebnf.Production) bool {
+	p.skip()
+	name := x.Name.String
+	stats := p.prof.Prods[name]
+	stats.Hits++
+	p.active[name]++
+	begin := time.Now()
+	ret := p.evalExpr(x.Expr)
+	p.active[name]--
+	if p.active[name] == 0 {
+		stats.Time += time.Since(begin)
+	}
+	return ret
+}
+
+// evalExpr evaluates the given expression, and reports whether it matched the
+// input at the current position.
+func (p *parser) evalExpr(x ebnf.Expression) bool {
+	// skip whitespace and comments in between expressions.
+	p.skip()
+	switch x := x.(type) {
+	case nil:
+		return true
+	case ebnf.Alternative:
+		for _, e := range x {
+			bak := p.pos
+			if p.evalExpr(e) {
+				return true
+			}
+			p.pos = bak
+		}
+		return false
+	case ebnf.Sequence:
+		bak := p.pos
+		for _, e := range x {
+			if !p.evalExpr(e) {
+				p.pos = bak
+				return false
+			}
+		}
+		return true
+	case *ebnf.Name:
+		return p.evalProd(p.grammar[x.String])
+	case *ebnf.Token:
+		if !strings.HasPrefix(string(p.input[p.pos:]), x.String) {
+			return false
+		}
+		p.pos += len(x.String)
+		return true
+	case *ebnf.Range:
+		if p.pos >= len(p.input) {
+			return false
+		}
+		from, _ := utf8.DecodeRuneInString(x.Begin.String)
+		to, _ := utf8.DecodeRuneInString(x.End.String)
+		r, size := utf8.DecodeRune(p.input[p.pos:])
+		if r < from || r > to {
+			return false
+		}
+		p.pos += size
+		return true
+	case *ebnf.Group:
+		return p.evalExpr(x.Body)
+	case *ebnf.Option:
+		bak := p.pos
+		if !p.evalExpr(x.Body) {
+			p.pos = bak
+		}
+		return true
+	case *ebnf.Repetition:
+		for p.pos < len(p.input) {
+			bak := p.pos
+			if !p.evalExpr(x.Body) {
+				p.pos = bak
+				break
+			}
+			if p.pos == bak {
+				// body matched empty input; stop to prevent infinite loop.
+				break
+			}
+		}
+		return true
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// skip evaluates the skip production rule to ignore whitespace and comments.
+func (p *parser) skip() {
+	if p.skipping {
+		return
+	}
+	skip, ok := p.grammar[p.skipRule]
+	if !ok {
+		return
+	}
+	p.skipping = true
+	for p.pos < len(p.input) {
+		bak := p.pos
+		if !p.evalExpr(skip.Expr) || p.pos == bak {
+			p.pos = bak
+			break
+		}
+	}
+	p.skipping = false
+}
@@ -0,0 +1,175 @@
+// The grambench tool compares the evaluator performance of two versions of an
+// EBNF grammar on the same input.
+//
+// Both grammars are evaluated on the input, and the per production hit count
+// and evaluation time of each grammar are reported side by side, together with
+// the faster grammar of each production. Productions present in only one of the
+// grammars are reported with a hit count of "-" for the other grammar.
+//
+// Grammars are evaluated by a profiling evaluator with the semantics of the
+// runtime evaluator of package eval, as used by the speak tool; i.e. ordered
+// choice with backtracking, and whitespace and comments ignored by the skip
+// production rule. The runtime evaluator itself is not used, as the profiling
+// evaluator omits first-set pruning, the maximum nesting depth and parse tree
+// construction, so that the reported times reflect the structure of the grammars
+// rather than optimizations of the evaluator. Evaluation times thus differ from
+// those of speak.
+//
+// Left-recursive grammars are not supported by the evaluator and are reported
+// as errors; left recursion must be removed from a grammar before comparing it
+// against a right-recursive rewrite.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mewkiz/pkg/ioutilx"
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Usage: grambench [OPTION]... OLD.ebnf NEW.ebnf INPUT
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Start production rule.
+		start string
+		// Skip production rule.
+		skipRule string
+		// Number of evaluation runs.
+		n int
+	)
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.IntVar(&n, "n", 10, "number of evaluation runs of each grammar")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 3 || n < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	oldPath, newPath, inputPath := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+	input, err := ioutilx.ReadFile(inputPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	// Evaluate grammars on input.
+	oldProf, err := benchGrammar(oldPath, start, skipRule, input, n)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	newProf, err := benchGrammar(newPath, start, skipRule, input, n)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if err := printTable(oldPath, newPath, oldProf, newProf); err != nil {
+		log.Fatalf("%+v", err)
+	}
+}
+
+// benchGrammar evaluates the given input n times using the given grammar, and
+// returns the evaluation profile.
+func benchGrammar(grammarPath, start, skipRule string, input []byte, n int) (*Profile, error) {
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if err := analysis.Verify(grammar, skipRule, start); err != nil {
+		return nil, errors.Wrapf(err, "invalid grammar %q", grammarPath)
+	}
+	if cycles := analysis.DetectLeftRecursion(grammar); len(cycles) > 0 {
+		return nil, errors.Errorf("unable to evaluate left-recursive grammar %q; left-recursive productions %s", grammarPath, strings.Join(cycles[0], ", "))
+	}
+	prof, err := profile(grammar, start, skipRule, input, n)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to evaluate grammar %q", grammarPath)
+	}
+	return prof, nil
+}
+
+// printTable prints the evaluation profiles of the old and new grammar side by
+// side as a table to standard output.
+func printTable(oldPath, newPath string, oldProf, newProf *Profile) error {
+	names := make(map[string]bool)
+	for name := range oldProf.Prods {
+		names[name] = true
+	}
+	for name := range newProf.Prods {
+		names[name] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "old:\t%s\n", oldPath)
+	fmt.Fprintf(w, "new:\t%s\n", newPath)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "production\told hits\told time\tnew hits\tnew time\tfaster")
+	for _, name := range sorted {
+		oldStats, newStats := oldProf.Prods[name], newProf.Prods[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, hits(oldStats), duration(oldStats), hits(newStats), duration(newStats), faster(oldStats, newStats))
+	}
+	oldTotal, newTotal := &ProdStats{Time: oldProf.Total}, &ProdStats{Time: newProf.Total}
+	fmt.Fprintf(w, "total\t\t%s\t\t%s\t%s\n", duration(oldTotal), duration(newTotal), faster(oldTotal, newTotal))
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// hits returns the hit count of the given production statistics, or "-" if the
+// production is not present in the grammar.
+func hits(stats *ProdStats) string {
+	if stats == nil {
+		return "-"
+	}
+	return fmt.Sprint(stats.Hits)
+}
+
+// duration returns the evaluation time of the given production statistics, or
+// "-" if the production is not present in the grammar.
+func duration(stats *ProdStats) string {
+	if stats == nil {
+		return "-"
+	}
+	return stats.Time.Round(time.Microsecond).String()
+}
+
+// faster returns the faster grammar (old or new) of the given production
+// statistics, or an empty string if the production is not evaluated by both
+// grammars.
+func faster(oldStats, newStats *ProdStats) string {
+	switch {
+	case oldStats == nil || newStats == nil:
+		return ""
+	case oldStats.Time == 0 && newStats.Time == 0:
+		return ""
+	case newStats.Time < oldStats.Time:
+		return "new"
+	default:
+		return "old"
+	}
+}
@@ -0,0 +1,172 @@
+// The gramdoc tool generates Markdown documentation of EBNF grammars.
+//
+// The documentation starts with a table of contents linking to one section per
+// production, in source order. Each section contains the doc comment of the
+// production, if present, followed by the production in EBNF syntax.
+//
+// Doc comments are the comments immediately preceding a production, with no
+// blank line in between; either a /* block */ comment or consecutive // line
+// comments.
+//
+//	// Expr is an arithmetic expression.
+//	Expr = Term { "+" Term } .
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: gramdoc [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Document title.
+		title string
+	)
+	flag.StringVar(&output, "o", "", "output path (default standard output)")
+	flag.StringVar(&title, "title", "", "document title (default base name of FILE)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Generate Markdown documentation of grammar.
+	src, err := ioutil.ReadFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	grammar, err := ebnf.Parse(grammarPath, bytes.NewReader(src))
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	if len(title) == 0 {
+		base := filepath.Base(grammarPath)
+		title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	buf := &bytes.Buffer{}
+	writeDoc(buf, title, grammar, src)
+	if len(output) == 0 {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		return
+	}
+	if err := ioutil.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// writeDoc writes the Markdown documentation of the given grammar to w, with
+// doc comments extracted from the grammar source.
+func writeDoc(w io.Writer, title string, grammar ebnf.Grammar, src []byte) {
+	prods := format.Prods(grammar)
+	// Anchors of the production sections.
+	anchors := make(map[string]string)
+	used := map[string]int{"contents": 1}
+	for _, prod := range prods {
+		name := prod.Name.String
+		anchors[name] = anchor(name, used)
+	}
+	fmt.Fprintf(w, "# %s\n\n", title)
+	// Table of contents.
+	fmt.Fprintln(w, "## Contents")
+	fmt.Fprintln(w)
+	for _, prod := range prods {
+		name := prod.Name.String
+		fmt.Fprintf(w, "- [%s](#%s)\n", name, anchors[name])
+	}
+	// Production sections.
+	for _, prod := range prods {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "## %s\n\n", prod.Name.String)
+		if doc := docComment(src, prod.Pos().Offset); len(doc) > 0 {
+			fmt.Fprintf(w, "%s\n\n", doc)
+		}
+		fmt.Fprintln(w, "```ebnf")
+		fmt.Fprintln(w, format.SprintProd(prod))
+		fmt.Fprintln(w, "```")
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// docComment returns the text of the doc comment immediately preceding the
+// given offset of the grammar source, or an empty string if not present.
+func docComment(src []byte, offset int) string {
+	before := bytes.TrimRightFunc(src[:offset], unicode.IsSpace)
+	if bytes.Count(src[len(before):offset], []byte("\n")) > 1 {
+		// blank line between comment and production.
+		return ""
+	}
+	// Block comment.
+	if bytes.HasSuffix(before, []byte("*/")) {
+		start := bytes.LastIndex(before, []byte("/*"))
+		if start == -1 {
+			return ""
+		}
+		body := string(before[start+len("/*") : len(before)-len("*/")])
+		var lines []string
+		for _, line := range strings.Split(body, "\n") {
+			line = strings.TrimSpace(line)
+			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+			lines = append(lines, line)
+		}
+		return strings.TrimSpace(strings.Join(lines, "\n"))
+	}
+	// Consecutive line comments.
+	var lines []string
+	for len(before) > 0 {
+		start := bytes.LastIndexByte(before, '\n') + 1
+		line := strings.TrimSpace(string(before[start:]))
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		lines = append([]string{strings.TrimSpace(strings.TrimPrefix(line, "//"))}, lines...)
+		before = bytes.TrimRight(before[:start], " \t\r\n")
+		if start > 0 && bytes.Count(src[len(before):start], []byte("\n")) > 1 {
+			// blank line between line comments.
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// anchor returns the Markdown heading anchor of the given production name, as
+// generated by GitHub; the lowercase name, with a numeric suffix for names
+// which are equal after lowercasing. The used map tracks the number of times
+// each anchor has been used.
+func anchor(name string, used map[string]int) string {
+	a := strings.ToLower(name)
+	n := used[a]
+	used[a]++
+	if n > 0 {
+		return fmt.Sprintf("%s-%d", a, n)
+	}
+	return a
+}
@@ -0,0 +1,318 @@
+// The gramequiv tool checks whether two EBNF grammars accept the same language.
+//
+// As language equivalence is undecidable in general, the check is approximated
+// by enumerating all strings up to a given length (in runes) generated by each
+// grammar from its start production rule. Each enumerated string is then parsed
+// by the runtime evaluator of the speak tool using both grammars, and strings
+// accepted by only one of the grammars are reported as counterexamples.
+//
+// The enumeration only proposes candidate strings; acceptance is decided by the
+// runtime evaluator, which takes ordered choice and the skip production rule
+// (whitespace and comments) into account. A string generated by a grammar may
+// thus still be rejected by the same grammar, e.g. when an earlier alternative
+// of an ordered choice shadows a later one.
+//
+// Left-recursive grammars are not supported by the runtime evaluator and are
+// reported as errors.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/eval"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: gramequiv [OPTION]... FILE1 FILE2
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Start production rule.
+		start string
+		// Skip production rule.
+		skipRule string
+		// Maximum length of enumerated strings.
+		depth int
+		// Maximum number of enumerated strings per production rule.
+		maxStrings int
+	)
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production of each grammar)")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.IntVar(&depth, "depth", 5, "maximum length (in runes) of enumerated strings")
+	flag.IntVar(&maxStrings, "max-strings", 100000, "maximum number of enumerated strings per production rule")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	aPath, bPath := flag.Arg(0), flag.Arg(1)
+
+	// Parse grammars.
+	a, err := parseGrammar(aPath, start, skipRule)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	b, err := parseGrammar(bPath, start, skipRule)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	// Enumerate candidate strings of both grammars.
+	candidates := make(map[string]bool)
+	for _, g := range []*Grammar{a, b} {
+		ss, err := g.enum(depth, maxStrings)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		for s := range ss {
+			candidates[s] = true
+		}
+	}
+
+	// Check candidate strings using the runtime evaluator.
+	var onlyA, onlyB []string
+	for _, s := range sorted(candidates) {
+		inA, err := a.accepts(s)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		inB, err := b.accepts(s)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		switch {
+		case inA && !inB:
+			onlyA = append(onlyA, s)
+		case inB && !inA:
+			onlyB = append(onlyB, s)
+		}
+	}
+
+	// Report counterexamples.
+	for _, s := range onlyA {
+		fmt.Printf("accepted by %q but not %q: %q\n", aPath, bPath, s)
+	}
+	for _, s := range onlyB {
+		fmt.Printf("accepted by %q but not %q: %q\n", bPath, aPath, s)
+	}
+	if len(onlyA) > 0 || len(onlyB) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("grammars equivalent for strings up to length %d (%d strings)\n", depth, len(candidates))
+}
+
+// Grammar is an EBNF grammar with the configuration of its runtime evaluator.
+type Grammar struct {
+	// Path to EBNF grammar.
+	Path string
+	// EBNF grammar.
+	Grammar ebnf.Grammar
+	// Start production rule.
+	Start string
+	// Runtime evaluator configuration.
+	Config *eval.Config
+}
+
+// parseGrammar parses and verifies the given EBNF grammar, using the start
+// production rule (or first syntactic production rule if start is empty) and
+// the skip production rule for runtime evaluation.
+func parseGrammar(grammarPath, start, skipRule string) (*Grammar, error) {
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if err := analysis.Verify(grammar, skipRule, start); err != nil {
+		return nil, errors.Wrapf(err, "invalid grammar %q", grammarPath)
+	}
+	if cycles := analysis.DetectLeftRecursion(grammar); len(cycles) > 0 {
+		return nil, errors.Errorf("unable to evaluate left-recursive grammar %q; left-recursive productions %s", grammarPath, strings.Join(cycles[0], ", "))
+	}
+	g := &Grammar{
+		Path:    grammarPath,
+		Grammar: grammar,
+		Start:   start,
+		Config: &eval.Config{
+			SkipRule: skipRule,
+			First:    analysis.First(grammar),
+			Nullable: analysis.Nullable(grammar),
+		},
+	}
+	return g, nil
+}
+
+// enum returns the strings up to length n generated by the grammar from its
+// start production rule.
+func (g *Grammar) enum(n, maxStrings int) (map[string]bool, error) {
+	lang, err := language(g.Grammar, n, maxStrings)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to enumerate strings of grammar %q", g.Path)
+	}
+	return lang[g.Start], nil
+}
+
+// accepts reports whether the given string is accepted by the runtime evaluator
+// of the grammar.
+func (g *Grammar) accepts(s string) (bool, error) {
+	_, errs, _, err := eval.Parse(context.Background(), g.Grammar, []byte(s), g.Config, g.Start)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to evaluate grammar %q on %q", g.Path, s)
+	}
+	return len(errs) == 0, nil
+}
+
+// language returns the strings up to length n generated by each production
+// rule of the grammar.
+func language(grammar ebnf.Grammar, n, maxStrings int) (map[string]map[string]bool, error) {
+	e := &enumerator{
+		n:    n,
+		lang: make(map[string]map[string]bool),
+	}
+	for name := range grammar {
+		e.lang[name] = make(map[string]bool)
+	}
+	// Iterate until a fixed point is reached.
+	for changed := true; changed; {
+		changed = false
+		for _, name := range analysis.Names(grammar) {
+			ss := e.expr(grammar[name].Expr)
+			for s := range ss {
+				if !e.lang[name][s] {
+					e.lang[name][s] = true
+					changed = true
+				}
+			}
+			if len(e.lang[name]) > maxStrings {
+				return nil, errors.Errorf("production rule %q generates more than %d strings up to length %d", name, maxStrings, n)
+			}
+		}
+	}
+	return e.lang, nil
+}
+
+// enumerator enumerates the strings up to a maximum length generated by the
+// expressions of a grammar.
+type enumerator struct {
+	// Maximum length (in runes) of strings.
+	n int
+	// Strings generated by each production rule so far.
+	lang map[string]map[string]bool
+}
+
+// expr returns the strings generated by the given expression.
+func (e *enumerator) expr(x ebnf.Expression) map[string]bool {
+	switch x := x.(type) {
+	case nil:
+		return map[string]bool{"": true}
+	case ebnf.Alternative:
+		ss := make(map[string]bool)
+		for _, alt := range x {
+			for s := range e.expr(alt) {
+				ss[s] = true
+			}
+		}
+		return ss
+	case ebnf.Sequence:
+		ss := map[string]bool{"": true}
+		for _, elem := range x {
+			ss = e.concat(ss, e.expr(elem))
+		}
+		return ss
+	case *ebnf.Name:
+		return e.lang[x.String]
+	case *ebnf.Token:
+		ss := make(map[string]bool)
+		if utf8.RuneCountInString(x.String) <= e.n {
+			ss[x.String] = true
+		}
+		return ss
+	case *ebnf.Range:
+		ss := make(map[string]bool)
+		if e.n < 1 {
+			return ss
+		}
+		begin, _ := utf8.DecodeRuneInString(x.Begin.String)
+		end, _ := utf8.DecodeRuneInString(x.End.String)
+		for r := begin; r <= end; r++ {
+			ss[string(r)] = true
+		}
+		return ss
+	case *ebnf.Group:
+		return e.expr(x.Body)
+	case *ebnf.Option:
+		ss := map[string]bool{"": true}
+		for s := range e.expr(x.Body) {
+			ss[s] = true
+		}
+		return ss
+	case *ebnf.Repetition:
+		body := e.expr(x.Body)
+		ss := map[string]bool{"": true}
+		for {
+			n := len(ss)
+			for s := range e.concat(ss, body) {
+				ss[s] = true
+			}
+			if len(ss) == n {
+				return ss
+			}
+		}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// concat returns the concatenation of each string of xs with each string of
+// ys, omitting strings longer than the maximum length.
+func (e *enumerator) concat(xs, ys map[string]bool) map[string]bool {
+	// Index ys by length, to only consider pairs within the maximum length.
+	byLen := make([][]string, e.n+1)
+	for y := range ys {
+		l := utf8.RuneCountInString(y)
+		byLen[l] = append(byLen[l], y)
+	}
+	ss := make(map[string]bool)
+	for x := range xs {
+		for l := 0; l <= e.n-utf8.RuneCountInString(x); l++ {
+			for _, y := range byLen[l] {
+				ss[x+y] = true
+			}
+		}
+	}
+	return ss
+}
+
+// sorted returns the given strings ordered by length and lexicographically.
+func sorted(set map[string]bool) []string {
+	var ss []string
+	for s := range set {
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		if len(ss[i]) != len(ss[j]) {
+			return len(ss[i]) < len(ss[j])
+		}
+		return ss[i] < ss[j]
+	})
+	return ss
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/combinator"
+	"golang.org/x/exp/ebnf"
+)
+
+// inf denotes an infinite distance or height.
+const inf = math.MaxInt32
+
+// generator generates inputs exercising a target production rule by a
+// grammar-guided random walk.
+type generator struct {
+	// EBNF grammar.
+	grammar ebnf.Grammar
+	// Target production rule.
+	target string
+	// Expansion depth after which the shortest expansions are used.
+	maxDepth int
+	// Source of randomness.
+	rnd *rand.Rand
+	// Distance (in production expansions) of each production rule to the
+	// target production rule; absent if the target is not reachable.
+	dist map[string]int
+	// Minimum expansion height of each production rule.
+	height map[string]int
+	// Target production rule reached by the current walk.
+	reached bool
+	// Output of the current walk.
+	buf strings.Builder
+}
+
+// newGenerator returns a new generator of inputs exercising the target
+// production rule of the grammar.
+func newGenerator(grammar ebnf.Grammar, target string, maxDepth int, rnd *rand.Rand) *generator {
+	g := &generator{
+		grammar:  grammar,
+		target:   target,
+		maxDepth: maxDepth,
+		rnd:      rnd,
+		dist:     map[string]int{target: 0},
+		height:   make(map[string]int),
+	}
+	// Breadth-first search from the target production along references.
+	sites := analysis.UsageSites(grammar)
+	queue := []string{target}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, site := range sites[name] {
+			if _, ok := g.dist[site.Production]; !ok {
+				g.dist[site.Production] = g.dist[name] + 1
+				queue = append(queue, site.Production)
+			}
+		}
+	}
+	// Iterate until a fixed point is reached.
+	for name := range grammar {
+		g.height[name] = inf
+	}
+	for changed := true; changed; {
+		changed = false
+		for name, prod := range grammar {
+			if h := g.exprHeight(prod.Expr); h != inf && h+1 < g.height[name] {
+				g.height[name] = h + 1
+				changed = true
+			}
+		}
+	}
+	return g
+}
+
+// reachable reports whether the target production rule is reachable from the
+// given production rule.
+func (g *generator) reachable(name string) bool {
+	_, ok := g.dist[name]
+	return ok
+}
+
+// generate returns an input derived from the given start production rule.
+func (g *generator) generate(start string) string {
+	g.reached = false
+	g.buf.Reset()
+	g.name(start, 0)
+	return g.buf.String()
+}
+
+// name generates an input derived from the given production rule.
+func (g *generator) name(name string, depth int) {
+	if name == g.target {
+		g.reached = true
+	}
+	prod, ok := g.grammar[name]
+	if !ok {
+		// missing production.
+		return
+	}
+	g.expr(prod.Expr, depth+1)
+}
+
+// expr generates an input derived from the given expression.
+func (g *generator) expr(x ebnf.Expression, depth int) {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+	case ebnf.Alternative:
+		g.expr(x[g.pickAlt(x, depth)], depth)
+	case ebnf.Sequence:
+		for _, e := range x {
+			g.expr(e, depth)
+		}
+	case *ebnf.Name:
+		g.name(x.String, depth)
+	case *ebnf.Token:
+		g.buf.WriteString(x.String)
+	case *ebnf.Range:
+		begin, _ := utf8.DecodeRuneInString(x.Begin.String)
+		end, _ := utf8.DecodeRuneInString(x.End.String)
+		g.buf.WriteRune(begin + rune(g.rnd.Intn(int(end-begin)+1)))
+	case *ebnf.Group:
+		g.expr(x.Body, depth)
+	case *ebnf.Option:
+		switch {
+		case !g.reached && g.exprDist(x.Body) != inf:
+			g.expr(x.Body, depth)
+		case depth > g.maxDepth:
+			// shortest expansion.
+		case g.rnd.Intn(2) == 0:
+			g.expr(x.Body, depth)
+		}
+	case *ebnf.Repetition:
+		n := 0
+		switch {
+		case !g.reached && g.exprDist(x.Body) != inf:
+			n = 1
+		case depth > g.maxDepth:
+			// shortest expansion.
+		default:
+			n = g.rnd.Intn(3)
+		}
+		for i := 0; i < n; i++ {
+			g.expr(x.Body, depth)
+		}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// pickAlt returns the index of the alternative to expand; the alternative
+// closest to the target production rule if not yet reached, the shortest
+// alternative if the maximum depth has been exceeded, or a random alternative
+// otherwise.
+func (g *generator) pickAlt(x ebnf.Alternative, depth int) int {
+	var metric func(e ebnf.Expression) int
+	switch {
+	case !g.reached:
+		metric = g.exprDist
+	case depth > g.maxDepth:
+		metric = g.exprHeight
+	default:
+		return g.rnd.Intn(len(x))
+	}
+	var best []int
+	min := inf
+	for i, e := range x {
+		switch m := metric(e); {
+		case m < min:
+			min = m
+			best = []int{i}
+		case m == min:
+			best = append(best, i)
+		}
+	}
+	return best[g.rnd.Intn(len(best))]
+}
+
+// exprDist returns the distance of the given expression to the target
+// production rule.
+func (g *generator) exprDist(x ebnf.Expression) int {
+	switch x := x.(type) {
+	case nil, *ebnf.Token, *ebnf.Range:
+		return inf
+	case ebnf.Alternative:
+		return g.minDist(x)
+	case ebnf.Sequence:
+		return g.minDist(x)
+	case *ebnf.Name:
+		if d, ok := g.dist[x.String]; ok {
+			return d
+		}
+		return inf
+	case *ebnf.Group:
+		return g.exprDist(x.Body)
+	case *ebnf.Option:
+		return g.exprDist(x.Body)
+	case *ebnf.Repetition:
+		return g.exprDist(x.Body)
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// minDist returns the minimum distance of the given expressions to the target
+// production rule.
+func (g *generator) minDist(xs []ebnf.Expression) int {
+	min := inf
+	for _, e := range xs {
+		if d := g.exprDist(e); d < min {
+			min = d
+		}
+	}
+	return min
+}
+
+// exprHeight returns the minimum expansion height of the given expression.
+func (g *generator) exprHeight(x ebnf.Expression) int {
+	switch x := x.(type) {
+	case nil, *ebnf.Token, *ebnf.Range, *ebnf.Option, *ebnf.Repetition:
+		return 0
+	case ebnf.Alternative:
+		min := inf
+		for _, e := range x {
+			if h := g.exprHeight(e); h < min {
+				min = h
+			}
+		}
+		return min
+	case ebnf.Sequence:
+		max := 0
+		for _, e := range x {
+			if h := g.exprHeight(e); h > max {
+				max = h
+			}
+		}
+		return max
+	case *ebnf.Name:
+		if h, ok := g.height[x.String]; ok {
+			return h
+		}
+		return 0
+	case *ebnf.Group:
+		return g.exprHeight(x.Body)
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// newAcceptor returns a function reporting whether the given input is accepted
+// by the grammar from the start production rule.
+func newAcceptor(grammar ebnf.Grammar, start string) func(input string) bool {
+	parsers := make(map[string]combinator.Parser)
+	for name, prod := range grammar {
+		parsers[name] = compile(prod.Expr, parsers)
+	}
+	return func(input string) bool {
+		p, ok := parsers[start]
+		if !ok {
+			return false
+		}
+		pos, ok := p([]byte(input), 0)
+		return ok && pos == len(input)
+	}
+}
+
+// compile returns a parser of the given expression, where production names are
+// resolved through parsers.
+func compile(x ebnf.Expression, parsers map[string]combinator.Parser) combinator.Parser {
+	switch x := x.(type) {
+	case nil:
+		return combinator.Tok("")
+	case ebnf.Alternative:
+		var ps []combinator.Parser
+		for _, e := range x {
+			ps = append(ps, compile(e, parsers))
+		}
+		return combinator.Alt(ps...)
+	case ebnf.Sequence:
+		var ps []combinator.Parser
+		for _, e := range x {
+			ps = append(ps, compile(e, parsers))
+		}
+		return combinator.Seq(ps...)
+	case *ebnf.Name:
+		name := x.String
+		return func(input []byte, pos int) (int, bool) {
+			p, ok := parsers[name]
+			if !ok {
+				// missing production.
+				return pos, false
+			}
+			return p(input, pos)
+		}
+	case *ebnf.Token:
+		return combinator.Tok(x.String)
+	case *ebnf.Range:
+		begin, _ := utf8.DecodeRuneInString(x.Begin.String)
+		end, _ := utf8.DecodeRuneInString(x.End.String)
+		return combinator.Range(begin, end)
+	case *ebnf.Group:
+		return compile(x.Body, parsers)
+	case *ebnf.Option:
+		return combinator.Opt(compile(x.Body, parsers))
+	case *ebnf.Repetition:
+		return combinator.Rep(compile(x.Body, parsers))
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
@@ -0,0 +1,132 @@
+// The gramfuzz tool generates fuzzing corpora from EBNF grammars.
+//
+// For each production rule reachable from the start production rule, an input
+// exercising the production rule is generated by a grammar-guided random walk
+// from the start production rule, which is forced to reach the production rule.
+// Each input is verified to be accepted by a parser of the grammar, which uses
+// ordered choice in the same way as the runtime evaluator of the speak tool.
+//
+// The corpus is written as one file per production rule to the output
+// directory, in the corpus file format of go test -fuzz.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+)
+
+var (
+	// warn is a logger with the "gramfuzz:" prefix which logs warning messages
+	// to standard error.
+	warn = log.New(os.Stderr, term.RedBold("gramfuzz:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Usage: gramfuzz [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output directory.
+		outputDir string
+		// Start production rule.
+		start string
+		// Skip production rule.
+		skipRule string
+		// Seed of random walk.
+		seed int64
+		// Expansion depth after which the shortest expansions are used.
+		maxDepth int
+		// Number of attempts at generating an accepted input per production.
+		attempts int
+		// Write raw inputs instead of go test -fuzz corpus files.
+		raw bool
+	)
+	flag.StringVar(&outputDir, "o", "corpus", "output directory")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.Int64Var(&seed, "seed", 1, "seed of random walk")
+	flag.IntVar(&maxDepth, "max-depth", 10, "expansion depth after which the shortest expansions are used")
+	flag.IntVar(&attempts, "attempts", 100, "number of attempts at generating an accepted input per production")
+	flag.BoolVar(&raw, "raw", false, "write raw inputs instead of go test -fuzz corpus files")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Generate corpus.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if _, ok := grammar[start]; !ok {
+		log.Fatalf("unable to locate start production rule %q in grammar %q", start, grammarPath)
+	}
+	// The acceptor, like the runtime evaluator, does not terminate on
+	// left-recursive grammars.
+	if cycles := analysis.DetectLeftRecursion(grammar); len(cycles) > 0 {
+		log.Fatalf("unable to generate corpus for left-recursive grammar %q; left-recursive production rules %v", grammarPath, cycles)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	accepts := newAcceptor(grammar, start)
+	for _, target := range analysis.Names(grammar) {
+		if target == skipRule {
+			continue
+		}
+		g := newGenerator(grammar, target, maxDepth, rnd)
+		if !g.reachable(start) {
+			warn.Printf("production rule %q not reachable from start production rule %q; skipping", target, start)
+			continue
+		}
+		input, ok := "", false
+		for i := 0; i < attempts && !ok; i++ {
+			input = g.generate(start)
+			ok = accepts(input)
+		}
+		if !ok {
+			warn.Printf("unable to generate accepted input for production rule %q in %d attempts; skipping", target, attempts)
+			continue
+		}
+		if err := writeInput(outputDir, target, input, raw); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+}
+
+// writeInput writes the given input exercising the production rule to the
+// output directory.
+func writeInput(outputDir, target, input string, raw bool) error {
+	buf := []byte(input)
+	if !raw {
+		buf = []byte(fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", input))
+	}
+	path := filepath.Join(outputDir, target)
+	if err := ioutil.WriteFile(path, buf, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
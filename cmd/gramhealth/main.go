@@ -0,0 +1,394 @@
+// The gramhealth tool reports the health of EBNF grammars, by running all
+// available analyses in a single pass.
+//
+// The following checks are performed:
+//
+//   - validation: the grammar is syntactically valid, productions used are
+//     defined, and lexical productions only refer to lexical productions
+//   - unreachable: productions are reachable from the start production
+//   - left-recursion: productions are not left-recursive
+//   - ll1-conflict: alternatives have disjoint FIRST sets
+//   - dead-alternative: alternatives are not shadowed by earlier alternatives
+//     which are identical to them or to a prefix of them
+//   - possibly-dead-alternative: FIRST sets of alternatives are not covered by
+//     the FIRST sets of earlier alternatives (warning)
+//   - nullable: nullable productions (informational)
+//
+// The report starts with a summary section of the check results, followed by
+// the findings of each check and complexity metrics of the grammar. The exit
+// status is 0 if all checks pass (warnings and informational checks never
+// fail), and 1 otherwise.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: gramhealth [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output format (text or json).
+		outputFormat string
+		// Start production rule.
+		start string
+		// Skip production rule.
+		skipRule string
+	)
+	flag.StringVar(&outputFormat, "format", "text", "output format (text or json)")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Report grammar health.
+	report, err := health(grammarPath, start, skipRule)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	switch outputFormat {
+	case "text":
+		err = printText(report)
+	case "json":
+		err = printJSON(report)
+	default:
+		log.Fatalf("invalid output format %q; expected text or json", outputFormat)
+	}
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if !report.Pass {
+		os.Exit(1)
+	}
+}
+
+// Check statuses.
+const (
+	statusPass = "pass"
+	statusFail = "fail"
+	statusSkip = "skip"
+	statusWarn = "warn"
+	statusInfo = "info"
+)
+
+// Report is the health report of an EBNF grammar.
+type Report struct {
+	// Path to EBNF grammar.
+	Path string `json:"path"`
+	// Start production rule.
+	Start string `json:"start"`
+	// Reports whether all checks passed.
+	Pass bool `json:"pass"`
+	// Check results.
+	Checks []*Check `json:"checks"`
+	// Complexity metrics; nil if the grammar is syntactically invalid.
+	Metrics *Metrics `json:"metrics,omitempty"`
+}
+
+// Check is the result of a check of an EBNF grammar.
+type Check struct {
+	// Check name.
+	Name string `json:"name"`
+	// Check status (pass, fail, skip, warn or info).
+	Status string `json:"status"`
+	// Findings of the check.
+	Findings []string `json:"findings"`
+}
+
+// newCheck returns a new check of the given name and status, without findings.
+func newCheck(name, status string) *Check {
+	return &Check{Name: name, Status: status, Findings: []string{}}
+}
+
+// Metrics holds the complexity metrics of an EBNF grammar.
+type Metrics struct {
+	// Number of syntactic production rules.
+	Syntactic int `json:"syntactic"`
+	// Number of lexical production rules.
+	Lexical int `json:"lexical"`
+	// Expression depth of the deepest production rule.
+	MaxDepth int `json:"max_depth"`
+	// Name of the deepest production rule.
+	Deepest string `json:"deepest"`
+	// Average number of alternatives per production rule.
+	Branching float64 `json:"branching"`
+}
+
+// health returns the health report of the given EBNF grammar.
+func health(grammarPath, start, skipRule string) (*Report, error) {
+	report := &Report{
+		Path: grammarPath,
+	}
+	src, err := ioutil.ReadFile(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	grammar, err := ebnf.Parse(grammarPath, bytes.NewReader(src))
+	if err != nil {
+		validation := newCheck("validation", statusFail)
+		for _, e := range analysis.SplitErrors(err) {
+			validation.Findings = append(validation.Findings, e.Error())
+		}
+		report.Checks = append(report.Checks, validation)
+		// The remaining checks require a syntactically valid grammar.
+		for _, name := range []string{"unreachable", "left-recursion", "ll1-conflict", "dead-alternative", "possibly-dead-alternative", "nullable"} {
+			report.Checks = append(report.Checks, newCheck(name, statusSkip))
+		}
+		return report, nil
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	report.Start = start
+
+	// Validation and unreachable productions.
+	validation := newCheck("validation", "")
+	unreachable := newCheck("unreachable", "")
+	if err := analysis.Verify(grammar, skipRule, start); err != nil {
+		for _, e := range analysis.SplitErrors(err) {
+			msg := e.Error()
+			if name := strings.TrimSuffix(msg, " is unreachable"); name != msg {
+				unreachable.Findings = append(unreachable.Findings, fmt.Sprintf("%s is unreachable from start production %s", name, start))
+				continue
+			}
+			validation.Findings = append(validation.Findings, msg)
+		}
+	}
+
+	// Left recursion.
+	leftRec := newCheck("left-recursion", "")
+	for _, cycle := range analysis.DetectLeftRecursion(grammar) {
+		prod := grammar[cycle[0]]
+		leftRec.Findings = append(leftRec.Findings, fmt.Sprintf("%v: left-recursive cycle %s", prod.Pos(), strings.Join(append(cycle, cycle[0]), " -> ")))
+	}
+
+	// LL(1) conflicts.
+	ll1 := newCheck("ll1-conflict", "")
+	first, nullable := analysis.First(grammar), analysis.Nullable(grammar)
+	for _, name := range analysis.Names(grammar) {
+		ll1.Findings = append(ll1.Findings, conflicts(name, grammar[name].Expr, first, nullable)...)
+	}
+
+	// Dead and possibly dead alternatives.
+	dead := newCheck("dead-alternative", "")
+	possiblyDead := newCheck("possibly-dead-alternative", statusPass)
+	_, diags := analysis.RemoveDeadAlternatives(grammar)
+	for _, d := range diags {
+		if !d.Proven {
+			possiblyDead.Status = statusWarn
+			possiblyDead.Findings = append(possiblyDead.Findings, fmt.Sprintf("%v: alternative %s of production %s may be shadowed by an earlier alternative beginning with the same input", d.Alt.Pos(), format.SprintExpr(d.Alt), d.Production))
+			continue
+		}
+		dead.Findings = append(dead.Findings, fmt.Sprintf("%v: alternative %s of production %s is shadowed by an earlier alternative", d.Alt.Pos(), format.SprintExpr(d.Alt), d.Production))
+	}
+
+	// Nullable productions.
+	nullables := newCheck("nullable", statusInfo)
+	for _, name := range analysis.Names(grammar) {
+		if nullable[name] {
+			nullables.Findings = append(nullables.Findings, fmt.Sprintf("%v: production %s is nullable", grammar[name].Pos(), name))
+		}
+	}
+
+	report.Checks = []*Check{validation, unreachable, leftRec, ll1, dead, possiblyDead, nullables}
+	report.Pass = true
+	for _, check := range report.Checks {
+		if len(check.Status) > 0 {
+			continue
+		}
+		check.Status = statusPass
+		if len(check.Findings) > 0 {
+			check.Status = statusFail
+			report.Pass = false
+		}
+	}
+	report.Metrics = metrics(grammar)
+	return report, nil
+}
+
+// conflicts returns the LL(1) conflicts of the alternative expressions of the
+// given production; i.e. pairs of alternatives with overlapping FIRST sets.
+func conflicts(name string, x ebnf.Expression, first map[string]analysis.Set, nullable map[string]bool) []string {
+	var findings []string
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+	case ebnf.Alternative:
+		firsts := make([]analysis.Set, len(x))
+		for i, e := range x {
+			firsts[i] = analysis.FirstExpr(e, first, nullable)
+			findings = append(findings, conflicts(name, e, first, nullable)...)
+		}
+		for i := range x {
+			for j := i + 1; j < len(x); j++ {
+				if t, ok := overlap(firsts[i], firsts[j]); ok {
+					findings = append(findings, fmt.Sprintf("%v: alternatives %s and %s of production %s both begin with %v", x[j].Pos(), format.SprintExpr(x[i]), format.SprintExpr(x[j]), name, t))
+				}
+			}
+		}
+	case ebnf.Sequence:
+		for _, e := range x {
+			findings = append(findings, conflicts(name, e, first, nullable)...)
+		}
+	case *ebnf.Name, *ebnf.Token, *ebnf.Range, *ebnf.Bad:
+		// no alternatives.
+	case *ebnf.Group:
+		findings = append(findings, conflicts(name, x.Body, first, nullable)...)
+	case *ebnf.Option:
+		findings = append(findings, conflicts(name, x.Body, first, nullable)...)
+	case *ebnf.Repetition:
+		findings = append(findings, conflicts(name, x.Body, first, nullable)...)
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+	return findings
+}
+
+// overlap returns a terminal of s2 which overlaps with a terminal of s, and a
+// boolean indicating if one was found.
+func overlap(s, s2 analysis.Set) (analysis.Terminal, bool) {
+	for _, t := range s2.Sorted() {
+		for _, u := range s.Sorted() {
+			if overlaps(u, t) {
+				return t, true
+			}
+		}
+	}
+	return analysis.Terminal{}, false
+}
+
+// overlaps reports whether the terminals u and t may begin the same input;
+// i.e. they are the same terminal, overlapping character ranges, or a token
+// beginning with a character within a character range.
+func overlaps(u, t analysis.Terminal) bool {
+	switch {
+	case u == t:
+		return true
+	case u == analysis.EOF, t == analysis.EOF:
+		return false
+	case len(u.Token) > 0 && len(t.Token) > 0:
+		return false
+	case len(u.Token) > 0:
+		r, _ := utf8.DecodeRuneInString(u.Token)
+		return t.Begin <= r && r <= t.End
+	case len(t.Token) > 0:
+		r, _ := utf8.DecodeRuneInString(t.Token)
+		return u.Begin <= r && r <= u.End
+	default:
+		return u.Begin <= t.End && t.Begin <= u.End
+	}
+}
+
+// metrics returns the complexity metrics of the given grammar.
+func metrics(grammar ebnf.Grammar) *Metrics {
+	m := &Metrics{}
+	alts := 0
+	for _, name := range analysis.Names(grammar) {
+		prod := grammar[name]
+		if analysis.IsLexical(name) {
+			m.Lexical++
+		} else {
+			m.Syntactic++
+		}
+		if depth := analysis.ExprDepth(prod.Expr); depth > m.MaxDepth {
+			m.MaxDepth = depth
+			m.Deepest = name
+		}
+		if alt, ok := prod.Expr.(ebnf.Alternative); ok {
+			alts += len(alt)
+		} else {
+			alts++
+		}
+	}
+	if len(grammar) > 0 {
+		m.Branching = float64(alts) / float64(len(grammar))
+	}
+	return m
+}
+
+// printText prints the given health report in text format to standard output;
+// a summary section of the check results, followed by the findings of each
+// check and the complexity metrics.
+func printText(report *Report) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	failed := 0
+	for _, check := range report.Checks {
+		if check.Status == statusFail {
+			failed++
+		}
+	}
+	status := "PASS"
+	if !report.Pass {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "grammar:\t%s\n", report.Path)
+	if len(report.Start) > 0 {
+		fmt.Fprintf(w, "start:\t%s\n", report.Start)
+	}
+	fmt.Fprintf(w, "status:\t%s (%d of %d checks failed)\n", status, failed, len(report.Checks))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "summary:")
+	for _, check := range report.Checks {
+		fmt.Fprintf(w, "   %s\t%s\t(%d)\n", strings.ToUpper(check.Status), check.Name, len(check.Findings))
+	}
+	for _, check := range report.Checks {
+		if len(check.Findings) == 0 {
+			continue
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%s:\n", check.Name)
+		for _, finding := range check.Findings {
+			fmt.Fprintf(w, "   %s\n", finding)
+		}
+	}
+	if m := report.Metrics; m != nil {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "metrics:")
+		fmt.Fprintf(w, "   productions:\t%d\n", m.Syntactic+m.Lexical)
+		fmt.Fprintf(w, "   syntactic:\t%d\n", m.Syntactic)
+		fmt.Fprintf(w, "   lexical:\t%d\n", m.Lexical)
+		fmt.Fprintf(w, "   max depth:\t%d (%s)\n", m.MaxDepth, m.Deepest)
+		fmt.Fprintf(w, "   branching:\t%.2f\n", m.Branching)
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// printJSON prints the given health report in JSON format to standard output.
+func printJSON(report *Report) error {
+	buf, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Println(string(buf))
+	return nil
+}
@@ -0,0 +1,192 @@
+// The graminterface tool generates Go source code for grammar-driven dispatch
+// of parse trees, from EBNF grammars.
+//
+// The generated Handler interface has one method per syntactic production rule,
+// and the Dispatcher calls the method of the production rule of a given parse
+// tree node. Parse tree nodes of lexical production rules are not dispatched.
+//
+//	type Handler interface {
+//	    HandleExpr(node *ParseNode) error
+//	    HandleTerm(node *ParseNode) error
+//	}
+//
+// The parse tree node type is expected to be declared in the package of the
+// generated source code, and to have a Name field holding the production name.
+// With -node, a ParseNode type is generated which matches the JSON parse trees
+// output by the speak tool (-output-format json).
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: graminterface [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Package name of generated source code.
+		pkgName string
+		// Parse tree node type.
+		nodeType string
+		// Generate ParseNode type.
+		genNode bool
+	)
+	flag.StringVar(&output, "o", "", "output path (default standard output)")
+	flag.StringVar(&pkgName, "pkg", "main", "package name of generated source code")
+	flag.StringVar(&nodeType, "node-type", "*ParseNode", "parse tree node type, with a Name field holding the production name")
+	flag.BoolVar(&genNode, "node", false, "generate a ParseNode type matching the JSON parse trees of speak")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Generate Go source code of grammar.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	g := &generator{
+		grammarName: filepath.Base(grammarPath),
+		pkgName:     pkgName,
+		nodeType:    nodeType,
+		genNode:     genNode,
+	}
+	src, err := g.generate(grammar)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if len(output) == 0 {
+		if _, err := os.Stdout.Write(src); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		return
+	}
+	if err := ioutil.WriteFile(output, src, 0644); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// generator generates Go source code for grammar-driven dispatch.
+type generator struct {
+	// Base name of grammar.
+	grammarName string
+	// Package name of generated source code.
+	pkgName string
+	// Parse tree node type.
+	nodeType string
+	// Generate ParseNode type.
+	genNode bool
+}
+
+// generate returns the formatted Go source code of the Handler interface and
+// Dispatcher of the given grammar.
+func (g *generator) generate(grammar ebnf.Grammar) ([]byte, error) {
+	var syntactic, lexical []string
+	for name := range grammar {
+		if analysis.IsLexical(name) {
+			lexical = append(lexical, name)
+		} else {
+			syntactic = append(syntactic, name)
+		}
+	}
+	sort.Strings(syntactic)
+	sort.Strings(lexical)
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by graminterface from %q; DO NOT EDIT.\n\n", g.grammarName)
+	fmt.Fprintf(buf, "package %s\n\n", g.pkgName)
+	buf.WriteString("import \"fmt\"\n\n")
+	if g.genNode {
+		buf.WriteString(nodeDef)
+	}
+	// Handler interface.
+	buf.WriteString("// Handler handles parse tree nodes of syntactic production rules.\n")
+	buf.WriteString("type Handler interface {\n")
+	for _, name := range syntactic {
+		fmt.Fprintf(buf, "\t// Handle%s handles a parse tree node of the %s production rule.\n", name, name)
+		fmt.Fprintf(buf, "\tHandle%s(node %s) error\n", name, g.nodeType)
+	}
+	buf.WriteString("}\n\n")
+	// Dispatcher.
+	buf.WriteString("// Dispatcher dispatches parse tree nodes to the methods of a handler, based\n")
+	buf.WriteString("// on the production name of the nodes.\n")
+	buf.WriteString("type Dispatcher struct {\n")
+	buf.WriteString("\t// Handler of parse tree nodes.\n")
+	buf.WriteString("\tHandler Handler\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("// Dispatch calls the method of the handler corresponding to the production\n")
+	buf.WriteString("// rule of the given parse tree node. Nodes of lexical production rules are\n")
+	buf.WriteString("// not dispatched.\n")
+	fmt.Fprintf(buf, "func (d *Dispatcher) Dispatch(node %s) error {\n", g.nodeType)
+	buf.WriteString("\tswitch node.Name {\n")
+	for _, name := range syntactic {
+		fmt.Fprintf(buf, "\tcase %q:\n", name)
+		fmt.Fprintf(buf, "\t\treturn d.Handler.Handle%s(node)\n", name)
+	}
+	if len(lexical) > 0 {
+		buf.WriteString("\tcase ")
+		for i, name := range lexical {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(buf, "%q", name)
+		}
+		buf.WriteString(":\n")
+		buf.WriteString("\t\t// lexical production rule.\n")
+		buf.WriteString("\t\treturn nil\n")
+	}
+	buf.WriteString("\tdefault:\n")
+	buf.WriteString("\t\treturn fmt.Errorf(\"unknown production rule %q of parse tree node\", node.Name)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to format generated source code of %q", g.grammarName)
+	}
+	return src, nil
+}
+
+// nodeDef is the definition of the ParseNode type, matching the JSON parse
+// trees of speak.
+const nodeDef = `// ParseNode is a node of the parse tree, corresponding to a successfully
+// evaluated production rule.
+type ParseNode struct {
+	// Production name.
+	Name string ` + "`json:\"name\"`" + `
+	// Start offset in the input source.
+	Start int ` + "`json:\"start\"`" + `
+	// End offset in the input source.
+	End int ` + "`json:\"end\"`" + `
+	// Child nodes of syntactic production rules, in order of occurrence.
+	Children []*ParseNode ` + "`json:\"children,omitempty\"`" + `
+	// Matched source text.
+	Text string ` + "`json:\"text\"`" + `
+}
+
+`
+
+// ### [ Helper functions ] ####################################################
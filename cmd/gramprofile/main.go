@@ -0,0 +1,180 @@
+// The gramprofile tool reports production usage from parse traces of the speak
+// tool (as written by -trace-file), to identify hot and cold paths of a
+// grammar.
+//
+// For each production, the table reports the number of evaluations (hits), the
+// number of unique input positions evaluated at, and the maximum self-recursion
+// depth. Productions evaluated many more times than at unique positions are
+// backtracking hotspots. Productions are ordered by hit count, with productions
+// of more than 50% of the total hits highlighted in red, and productions of
+// less than 1% of the total hits in gray.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Usage: gramprofile [OPTION]... TRACE_FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Disable colors.
+		noColor bool
+	)
+	flag.BoolVar(&noColor, "no-color", false, "disable colors (e.g. when piping output)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	tracePath := flag.Arg(0)
+
+	// Profile production usage of parse trace.
+	stats, err := profileTrace(tracePath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if err := printTable(stats, !noColor); err != nil {
+		log.Fatalf("%+v", err)
+	}
+}
+
+// traceEvent is a parse trace event, as written by the speak tool.
+type traceEvent struct {
+	// Event kind (enter or exit).
+	Event string `json:"event"`
+	// Production name.
+	Prod string `json:"prod"`
+	// Position in input source.
+	Pos int `json:"pos"`
+	// Result of production rule evaluation; only present on exit.
+	Result *bool `json:"result,omitempty"`
+}
+
+// ProdStats holds the usage statistics of a production.
+type ProdStats struct {
+	// Production name.
+	Name string
+	// Number of evaluations of the production.
+	Hits int
+	// Unique input positions at which the production was evaluated.
+	Positions map[int]bool
+	// Maximum self-recursion depth; i.e. the maximum number of simultaneous
+	// evaluations of the production.
+	MaxDepth int
+	// Number of current evaluations of the production.
+	depth int
+}
+
+// profileTrace returns the usage statistics of the productions evaluated in
+// the given parse trace, ordered by hit count.
+func profileTrace(tracePath string) ([]*ProdStats, error) {
+	f, err := os.Open(tracePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	m := make(map[string]*ProdStats)
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var e traceEvent
+		if err := dec.Decode(&e); err != nil {
+			return nil, errors.Wrapf(err, "unable to decode trace event of %q", tracePath)
+		}
+		stats, ok := m[e.Prod]
+		if !ok {
+			stats = &ProdStats{Name: e.Prod, Positions: make(map[int]bool)}
+			m[e.Prod] = stats
+		}
+		switch e.Event {
+		case "enter":
+			stats.Hits++
+			stats.Positions[e.Pos] = true
+			stats.depth++
+			if stats.depth > stats.MaxDepth {
+				stats.MaxDepth = stats.depth
+			}
+		case "exit":
+			stats.depth--
+		default:
+			return nil, errors.Errorf("invalid trace event kind %q of %q; expected enter or exit", e.Event, tracePath)
+		}
+	}
+	var stats []*ProdStats
+	for _, s := range m {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Hits != stats[j].Hits {
+			return stats[i].Hits > stats[j].Hits
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats, nil
+}
+
+// Color code of gray text.
+const fgGray = "90"
+
+// printTable prints the given production usage statistics as a table to
+// standard output, with hot and cold productions highlighted if color is set.
+func printTable(stats []*ProdStats, color bool) error {
+	total := 0
+	for _, s := range stats {
+		total += s.Hits
+	}
+	// Lines are colored after alignment, as escape sequences would otherwise
+	// offset the alignment of columns.
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "production\thits\tunique positions\tmax depth\t% of hits")
+	percents := make([]float64, len(stats))
+	for i, s := range stats {
+		if total > 0 {
+			percents[i] = 100 * float64(s.Hits) / float64(total)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.2f\n", s.Name, s.Hits, len(s.Positions), s.MaxDepth, percents[i])
+	}
+	fmt.Fprintf(w, "total\t%d\t\t\t\n", total)
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, percent := range percents {
+		// skip header line.
+		line := &lines[1+i]
+		switch {
+		case !color:
+			// nothing to do.
+		case percent > 50:
+			*line = term.Red(strings.TrimSuffix(*line, "\n")) + "\n"
+		case percent < 1:
+			*line = term.Color(strings.TrimSuffix(*line, "\n"), fgGray) + "\n"
+		}
+	}
+	if _, err := os.Stdout.WriteString(strings.Join(lines, "")); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// The gramstats tool reports metrics of EBNF grammars.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: gramstats [OPTION]... FILE...
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output metrics in JSON format.
+		jsonOutput bool
+	)
+	flag.BoolVar(&jsonOutput, "json", false, "output metrics in JSON format")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Report metrics of grammars.
+	for _, grammarPath := range flag.Args() {
+		grammar, err := analysis.ParseFile(grammarPath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		stats := grammarStats(grammarPath, grammar)
+		if jsonOutput {
+			if err := printJSON(stats); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			continue
+		}
+		if err := printTable(stats); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+}
+
+// Stats holds the metrics of an EBNF grammar.
+type Stats struct {
+	// Path to EBNF grammar.
+	Path string `json:"path"`
+	// Number of syntactic production rules.
+	Syntactic int `json:"syntactic"`
+	// Number of lexical production rules.
+	Lexical int `json:"lexical"`
+	// Expression depth of the deepest production rule.
+	MaxDepth int `json:"max_depth"`
+	// Name of the deepest production rule.
+	Deepest string `json:"deepest"`
+	// Average number of alternatives per production rule.
+	Branching float64 `json:"branching"`
+	// Number of nullable production rules.
+	Nullable int `json:"nullable"`
+	// Number of left-recursive cycles.
+	LeftRecursive int `json:"left_recursive"`
+}
+
+// grammarStats returns the metrics of the given grammar.
+func grammarStats(grammarPath string, grammar ebnf.Grammar) *Stats {
+	stats := &Stats{
+		Path: grammarPath,
+	}
+	alts := 0
+	for _, name := range analysis.Names(grammar) {
+		prod := grammar[name]
+		if analysis.IsLexical(name) {
+			stats.Lexical++
+		} else {
+			stats.Syntactic++
+		}
+		if depth := analysis.ExprDepth(prod.Expr); depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+			stats.Deepest = name
+		}
+		if alt, ok := prod.Expr.(ebnf.Alternative); ok {
+			alts += len(alt)
+		} else {
+			alts++
+		}
+	}
+	if len(grammar) > 0 {
+		stats.Branching = float64(alts) / float64(len(grammar))
+	}
+	stats.Nullable = len(analysis.Nullable(grammar))
+	stats.LeftRecursive = len(analysis.DetectLeftRecursion(grammar))
+	return stats
+}
+
+// printTable prints the given grammar metrics as a table to standard output.
+func printTable(stats *Stats) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "grammar:\t%s\n", stats.Path)
+	fmt.Fprintf(w, "productions:\t%d\n", stats.Syntactic+stats.Lexical)
+	fmt.Fprintf(w, "   syntactic:\t%d\n", stats.Syntactic)
+	fmt.Fprintf(w, "   lexical:\t%d\n", stats.Lexical)
+	fmt.Fprintf(w, "max depth:\t%d (%s)\n", stats.MaxDepth, stats.Deepest)
+	fmt.Fprintf(w, "branching:\t%.2f\n", stats.Branching)
+	fmt.Fprintf(w, "nullable:\t%d\n", stats.Nullable)
+	fmt.Fprintf(w, "left-recursive cycles:\t%d\n", stats.LeftRecursive)
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// printJSON prints the given grammar metrics in JSON format to standard output.
+func printJSON(stats *Stats) error {
+	buf, err := json.MarshalIndent(stats, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Println(string(buf))
+	return nil
+}
@@ -0,0 +1,314 @@
+// The gramvsc tool generates the language configuration of a VS Code language
+// extension (language-configuration.json) from an EBNF grammar.
+//
+// The language configuration is inferred from the grammar as follows.
+//
+// Bracket pairs are the pairs of matching brackets ("(" and ")", "[" and "]",
+// "{" and "}", "<" and ">") which occur as tokens of the same sequence in
+// syntactic productions, with the opening bracket before the closing bracket.
+//
+// Comments are inferred from the productions referenced from the skip
+// production whose names contain "comment". A comment starting and ending with
+// a token is a block comment, and a comment starting with a token and ending
+// with a newline (or not ending with a token) is a line comment.
+//
+//	comment = "//" { not_newline } "\n" | "/*" { any } "*/" .
+//
+// String delimiters are inferred from lexical productions starting and ending
+// with the same quote character token (", ' or `).
+//
+//	string_lit = "\"" { char } "\"" .
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: gramvsc [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Skip production rule.
+		skipRule string
+	)
+	flag.StringVar(&output, "o", "language-configuration.json", "output path")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+
+	// Generate language configuration of grammar.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	conf := languageConfig(grammar, skipRule)
+	buf, err := json.MarshalIndent(conf, "", "\t")
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	buf = append(buf, '\n')
+	if err := ioutil.WriteFile(output, buf, 0644); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// LanguageConfig is the language configuration of a VS Code language
+// extension.
+type LanguageConfig struct {
+	// Comment syntax.
+	Comments *Comments `json:"comments,omitempty"`
+	// Bracket pairs.
+	Brackets [][2]string `json:"brackets"`
+	// Character pairs automatically closed when typing the opening character.
+	AutoClosingPairs []AutoClosingPair `json:"autoClosingPairs"`
+	// Character pairs which may surround a selection.
+	SurroundingPairs [][2]string `json:"surroundingPairs"`
+}
+
+// Comments is the comment syntax of a language.
+type Comments struct {
+	// Start token of line comments.
+	LineComment string `json:"lineComment,omitempty"`
+	// Start and end token of block comments.
+	BlockComment *[2]string `json:"blockComment,omitempty"`
+}
+
+// AutoClosingPair is a character pair automatically closed when typing the
+// opening character.
+type AutoClosingPair struct {
+	// Opening character.
+	Open string `json:"open"`
+	// Closing character.
+	Close string `json:"close"`
+	// Scopes in which the pair is not automatically closed (e.g. string).
+	NotIn []string `json:"notIn,omitempty"`
+}
+
+// bracketPairs lists candidate bracket pairs in order of output.
+var bracketPairs = [][2]string{
+	{"{", "}"},
+	{"[", "]"},
+	{"(", ")"},
+	{"<", ">"},
+}
+
+// quotes lists candidate string delimiters in order of output.
+var quotes = []string{`"`, `'`, "`"}
+
+// languageConfig returns the language configuration inferred from the given
+// grammar.
+func languageConfig(grammar ebnf.Grammar, skipRule string) *LanguageConfig {
+	conf := &LanguageConfig{
+		Brackets:         [][2]string{},
+		AutoClosingPairs: []AutoClosingPair{},
+		SurroundingPairs: [][2]string{},
+	}
+	// Bracket pairs.
+	pairs := make(map[[2]string]bool)
+	for name, prod := range grammar {
+		if !analysis.IsLexical(name) {
+			findPairs(prod.Expr, pairs)
+		}
+	}
+	for _, pair := range bracketPairs {
+		if pairs[pair] {
+			conf.Brackets = append(conf.Brackets, pair)
+			conf.AutoClosingPairs = append(conf.AutoClosingPairs, AutoClosingPair{Open: pair[0], Close: pair[1]})
+			conf.SurroundingPairs = append(conf.SurroundingPairs, pair)
+		}
+	}
+	// String delimiters.
+	delims := make(map[string]bool)
+	for name, prod := range grammar {
+		if !analysis.IsLexical(name) {
+			continue
+		}
+		for _, alt := range alternatives(prod.Expr) {
+			first, last := bounds(alt)
+			if len(first) > 0 && first == last {
+				delims[first] = true
+			}
+		}
+	}
+	for _, quote := range quotes {
+		if delims[quote] {
+			conf.AutoClosingPairs = append(conf.AutoClosingPairs, AutoClosingPair{Open: quote, Close: quote, NotIn: []string{"string"}})
+			conf.SurroundingPairs = append(conf.SurroundingPairs, [2]string{quote, quote})
+		}
+	}
+	// Comments.
+	comments := &Comments{}
+	for _, name := range reachable(grammar, skipRule) {
+		if !strings.Contains(strings.ToLower(name), "comment") {
+			continue
+		}
+		for _, alt := range alternatives(grammar[name].Expr) {
+			first, last := bounds(alt)
+			switch {
+			case len(first) == 0:
+				// not a comment starting with a token.
+			case last == "\n" || len(last) == 0:
+				if len(comments.LineComment) == 0 {
+					comments.LineComment = first
+				}
+			default:
+				if comments.BlockComment == nil {
+					comments.BlockComment = &[2]string{first, last}
+				}
+			}
+		}
+	}
+	if len(comments.LineComment) > 0 || comments.BlockComment != nil {
+		conf.Comments = comments
+	}
+	return conf
+}
+
+// findPairs records the bracket pairs occurring as tokens of the same sequence
+// within the given expression, with the opening bracket before the closing
+// bracket.
+func findPairs(x ebnf.Expression, pairs map[[2]string]bool) {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		for _, e := range x {
+			findPairs(e, pairs)
+		}
+	case ebnf.Sequence:
+		for i, e := range x {
+			start, ok := e.(*ebnf.Token)
+			if !ok {
+				continue
+			}
+			for _, pair := range bracketPairs {
+				if start.String != pair[0] {
+					continue
+				}
+				for _, f := range x[i+1:] {
+					if end, ok := f.(*ebnf.Token); ok && end.String == pair[1] {
+						pairs[pair] = true
+					}
+				}
+			}
+		}
+		for _, e := range x {
+			findPairs(e, pairs)
+		}
+	case *ebnf.Group:
+		findPairs(x.Body, pairs)
+	case *ebnf.Option:
+		findPairs(x.Body, pairs)
+	case *ebnf.Repetition:
+		findPairs(x.Body, pairs)
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// alternatives returns the alternatives of the given expression, with grouped
+// alternatives flattened.
+func alternatives(x ebnf.Expression) []ebnf.Expression {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		var alts []ebnf.Expression
+		for _, e := range x {
+			alts = append(alts, alternatives(e)...)
+		}
+		return alts
+	case *ebnf.Group:
+		return alternatives(x.Body)
+	default:
+		return []ebnf.Expression{x}
+	}
+}
+
+// bounds returns the first and last token of the given sequence expression. The
+// first token is empty if the sequence does not start with a token, and the
+// last token is empty if the sequence does not end with a token.
+func bounds(x ebnf.Expression) (first, last string) {
+	seq, ok := x.(ebnf.Sequence)
+	if !ok || len(seq) < 2 {
+		return "", ""
+	}
+	if tok, ok := seq[0].(*ebnf.Token); ok {
+		first = tok.String
+	}
+	if tok, ok := seq[len(seq)-1].(*ebnf.Token); ok {
+		last = tok.String
+	}
+	return first, last
+}
+
+// reachable returns the names of the productions reachable from the given
+// production (including itself), in order of discovery.
+func reachable(grammar ebnf.Grammar, name string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		prod, ok := grammar[name]
+		if !ok || seen[name] {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+		for _, use := range referencedNames(prod.Expr) {
+			visit(use)
+		}
+	}
+	visit(name)
+	return names
+}
+
+// referencedNames returns the production names referenced by the given
+// expression, in order of occurrence.
+func referencedNames(x ebnf.Expression) []string {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		var names []string
+		for _, e := range x {
+			names = append(names, referencedNames(e)...)
+		}
+		return names
+	case ebnf.Sequence:
+		var names []string
+		for _, e := range x {
+			names = append(names, referencedNames(e)...)
+		}
+		return names
+	case *ebnf.Name:
+		return []string{x.String}
+	case *ebnf.Group:
+		return referencedNames(x.Body)
+	case *ebnf.Option:
+		return referencedNames(x.Body)
+	case *ebnf.Repetition:
+		return referencedNames(x.Body)
+	default:
+		return nil
+	}
+}
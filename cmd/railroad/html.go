@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// htmlTmpl is the template of self-contained HTML pages of railroad diagrams,
+// with the diagram of each production in a collapsible details element, and a
+// search box filtering productions by name.
+var htmlTmpl = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ html .Title }}</title>
+<style>
+body { font-family: sans-serif; margin: 20px; }
+#search { font-family: monospace; font-size: 14px; padding: 4px; width: 300px; margin-bottom: 10px; }
+details { margin: 4px 0; }
+summary { font-family: monospace; font-size: 14px; font-weight: bold; cursor: pointer; }
+{{ .Style }}
+</style>
+</head>
+<body>
+<h1>{{ html .Title }}</h1>
+<input id="search" type="search" placeholder="Filter productions by name">
+{{- range .Diagrams }}
+<details class="production" data-name="{{ html .Name }}" open>
+<summary>{{ html .Name }}</summary>
+{{ .SVG -}}
+</details>
+{{- end }}
+<script>
+document.getElementById("search").addEventListener("input", function(e) {
+	var query = e.target.value.toLowerCase();
+	var prods = document.querySelectorAll("details.production");
+	for (var i = 0; i < prods.length; i++) {
+		var name = prods[i].getAttribute("data-name").toLowerCase();
+		prods[i].style.display = name.indexOf(query) === -1 ? "none" : "";
+	}
+});
+</script>
+</body>
+</html>
+`))
+
+// diagram is the railroad diagram of a production, as an SVG image.
+type diagram struct {
+	// Production name.
+	Name string
+	// SVG image of railroad diagram.
+	SVG string
+}
+
+// writeHTML writes the given rows of railroad diagrams as a self-contained HTML
+// page with the given title, with each row as a separate SVG image.
+func writeHTML(w io.Writer, title string, rs []*row) error {
+	data := struct {
+		Title    string
+		Style    string
+		Diagrams []diagram
+	}{
+		Title: title,
+		Style: style,
+	}
+	for _, r := range rs {
+		buf := &bytes.Buffer{}
+		width, height := r.width(), r.height()+margin
+		fmt.Fprintf(buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+		r.draw(buf, 0)
+		fmt.Fprintln(buf, "</svg>")
+		data.Diagrams = append(data.Diagrams, diagram{Name: r.name, SVG: buf.String()})
+	}
+	if err := htmlTmpl.Execute(w, data); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// Layout dimensions in pixels.
+const (
+	// Width of a character in monospace font.
+	charWidth = 9
+	// Horizontal padding of text within boxes.
+	padding = 10
+	// Height of boxes.
+	boxHeight = 24
+	// Radius of rail curves.
+	radius = 10
+	// Width of rails to the left and right of branches and loops.
+	rail = 2 * radius
+	// Horizontal gap between sequential nodes.
+	hgap = 10
+	// Vertical gap between alternative branches.
+	vgap = 10
+)
+
+// node is a laid out railroad diagram node.
+type node interface {
+	// width returns the width of the node.
+	width() int
+	// up returns the height of the node above the baseline.
+	up() int
+	// down returns the height of the node below the baseline.
+	down() int
+	// draw draws the node with its entry point at (x, y) using SVG.
+	draw(w io.Writer, x, y int)
+}
+
+// newNode returns the railroad diagram node of the given EBNF expression.
+func newNode(x ebnf.Expression) node {
+	switch x := x.(type) {
+	case nil:
+		return &skip{}
+	case ebnf.Alternative:
+		c := &choice{}
+		for _, e := range x {
+			c.items = append(c.items, newNode(e))
+		}
+		return c
+	case ebnf.Sequence:
+		s := &seq{}
+		for _, e := range x {
+			s.items = append(s.items, newNode(e))
+		}
+		return s
+	case *ebnf.Name:
+		return &box{text: x.String, rounded: true}
+	case *ebnf.Token:
+		return &box{text: fmt.Sprintf("%q", x.String)}
+	case *ebnf.Range:
+		return &box{text: fmt.Sprintf("%q … %q", x.Begin.String, x.End.String)}
+	case *ebnf.Group:
+		return newNode(x.Body)
+	case *ebnf.Option:
+		// bypass on the baseline, body below.
+		return &choice{items: []node{&skip{}, newNode(x.Body)}}
+	case *ebnf.Repetition:
+		// optional loop; bypass on the baseline, body below.
+		return &choice{items: []node{&skip{}, &loop{item: newNode(x.Body)}}}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// --- [ skip ] ----------------------------------------------------------------
+
+// skip is an empty node.
+type skip struct{}
+
+func (n *skip) width() int                 { return 0 }
+func (n *skip) up() int                    { return 0 }
+func (n *skip) down() int                  { return 0 }
+func (n *skip) draw(w io.Writer, x, y int) {}
+
+// --- [ box ] -----------------------------------------------------------------
+
+// box is a terminal (rectangular) or non-terminal (rounded) box.
+type box struct {
+	// Box text.
+	text string
+	// Rounded box of non-terminal.
+	rounded bool
+}
+
+func (n *box) width() int {
+	return utf8.RuneCountInString(n.text)*charWidth + 2*padding
+}
+
+func (n *box) up() int   { return boxHeight / 2 }
+func (n *box) down() int { return boxHeight / 2 }
+
+func (n *box) draw(w io.Writer, x, y int) {
+	class, rx := "terminal", 0
+	if n.rounded {
+		class, rx = "nonterminal", boxHeight/2
+	}
+	fmt.Fprintf(w, "<rect class=%q x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"%d\"/>\n", class, x, y-n.up(), n.width(), boxHeight, rx)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\">%s</text>\n", x+n.width()/2, y, html.EscapeString(n.text))
+}
+
+// --- [ seq ] -----------------------------------------------------------------
+
+// seq is a sequence of nodes.
+type seq struct {
+	items []node
+}
+
+func (n *seq) width() int {
+	width := 0
+	for i, item := range n.items {
+		if i != 0 {
+			width += hgap
+		}
+		width += item.width()
+	}
+	return width
+}
+
+func (n *seq) up() int {
+	up := 0
+	for _, item := range n.items {
+		up = max(up, item.up())
+	}
+	return up
+}
+
+func (n *seq) down() int {
+	down := 0
+	for _, item := range n.items {
+		down = max(down, item.down())
+	}
+	return down
+}
+
+func (n *seq) draw(w io.Writer, x, y int) {
+	for i, item := range n.items {
+		if i != 0 {
+			line(w, x, y, x+hgap, y)
+			x += hgap
+		}
+		item.draw(w, x, y)
+		x += item.width()
+	}
+}
+
+// --- [ choice ] --------------------------------------------------------------
+
+// choice is a list of alternative nodes; the first on the baseline and the
+// remaining stacked below.
+type choice struct {
+	items []node
+}
+
+// offsets returns the vertical offset from the baseline of each branch.
+func (n *choice) offsets() []int {
+	offsets := make([]int, len(n.items))
+	for i := 1; i < len(n.items); i++ {
+		prev, item := n.items[i-1], n.items[i]
+		offsets[i] = offsets[i-1] + max(prev.down()+vgap+item.up(), 2*radius)
+	}
+	return offsets
+}
+
+func (n *choice) width() int {
+	width := 0
+	for _, item := range n.items {
+		width = max(width, item.width())
+	}
+	return width + 2*rail
+}
+
+func (n *choice) up() int {
+	return n.items[0].up()
+}
+
+func (n *choice) down() int {
+	offsets := n.offsets()
+	last := len(n.items) - 1
+	return offsets[last] + n.items[last].down()
+}
+
+func (n *choice) draw(w io.Writer, x, y int) {
+	width := n.width()
+	for i, item := range n.items {
+		by := y + n.offsets()[i]
+		if i == 0 {
+			line(w, x, y, x+rail, y)
+		} else {
+			fmt.Fprintf(w, "<path d=\"M%d %d Q%d %d %d %d L%d %d Q%d %d %d %d\"/>\n", x, y, x+radius, y, x+radius, y+radius, x+radius, by-radius, x+radius, by, x+rail, by)
+		}
+		item.draw(w, x+rail, by)
+		end := x + rail + item.width()
+		if i == 0 {
+			line(w, end, y, x+width, y)
+		} else {
+			right := x + width - radius
+			fmt.Fprintf(w, "<path d=\"M%d %d L%d %d Q%d %d %d %d L%d %d Q%d %d %d %d\"/>\n", end, by, right-radius, by, right, by, right, by-radius, right, y+radius, right, y, x+width, y)
+		}
+	}
+}
+
+// --- [ loop ] ----------------------------------------------------------------
+
+// loop is a node repeated one or more times, with the return path below.
+type loop struct {
+	item node
+}
+
+// offset returns the vertical offset from the baseline of the return path.
+func (n *loop) offset() int {
+	return max(n.item.down()+vgap, 2*radius)
+}
+
+func (n *loop) width() int { return n.item.width() + 2*rail }
+func (n *loop) up() int    { return n.item.up() }
+func (n *loop) down() int  { return n.offset() }
+
+func (n *loop) draw(w io.Writer, x, y int) {
+	line(w, x, y, x+rail, y)
+	n.item.draw(w, x+rail, y)
+	end := x + rail + n.item.width()
+	line(w, end, y, x+n.width(), y)
+	// return path.
+	by := y + n.offset()
+	left, right := x+radius, end+radius
+	fmt.Fprintf(w, "<path d=\"M%d %d Q%d %d %d %d L%d %d Q%d %d %d %d L%d %d Q%d %d %d %d L%d %d Q%d %d %d %d\"/>\n", end, y, right, y, right, y+radius, right, by-radius, right, by, end, by, x+rail, by, left, by, left, by-radius, left, y+radius, left, y, x+rail, y)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// line draws a straight line from (x1, y1) to (x2, y2) using SVG.
+func line(w io.Writer, x1, y1, x2, y2 int) {
+	if x1 == x2 && y1 == y2 {
+		return
+	}
+	fmt.Fprintf(w, "<path d=\"M%d %d L%d %d\"/>\n", x1, y1, x2, y2)
+}
@@ -0,0 +1,176 @@
+// The railroad tool generates railroad diagrams of EBNF grammars in SVG or HTML
+// format.
+//
+// The HTML output is a self-contained page, with the diagram of each production
+// in a collapsible section, and a search box filtering productions by name.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: railroad [OPTION]... FILE
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output path.
+		output string
+		// Output format (svg or html).
+		outputFormat string
+	)
+	flag.StringVar(&output, "o", "", "output path (default stdout)")
+	flag.StringVar(&outputFormat, "format", "svg", "output format (svg or html)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+	switch outputFormat {
+	case "svg", "html":
+		// valid output format.
+	default:
+		log.Fatalf("invalid output format %q; expected svg or html", outputFormat)
+	}
+
+	// Generate railroad diagrams.
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	w := os.Stdout
+	if len(output) > 0 {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	switch outputFormat {
+	case "svg":
+		writeSVG(bw, rows(grammar))
+	case "html":
+		if err := writeHTML(bw, filepath.Base(grammarPath), rows(grammar)); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+}
+
+// Row layout dimensions in pixels.
+const (
+	// Margin around rows.
+	margin = 20
+	// Height of production name labels.
+	labelHeight = 20
+	// Width of start and end markers.
+	markerWidth = 10
+)
+
+// row is the railroad diagram of a production, labeled by name.
+type row struct {
+	// Production name.
+	name string
+	// Railroad diagram of production expression.
+	n node
+}
+
+// rows returns the railroad diagrams of the syntactic productions of the
+// grammar, in source order.
+func rows(grammar ebnf.Grammar) []*row {
+	var prods []*ebnf.Production
+	for name, prod := range grammar {
+		if !analysis.IsLexical(name) {
+			prods = append(prods, prod)
+		}
+	}
+	sort.Slice(prods, func(i, j int) bool {
+		return prods[i].Pos().Offset < prods[j].Pos().Offset
+	})
+	var rs []*row
+	for _, prod := range prods {
+		r := &row{
+			name: prod.Name.String,
+			n:    newNode(prod.Expr),
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+func (r *row) width() int {
+	return 2*margin + 2*markerWidth + r.n.width()
+}
+
+func (r *row) height() int {
+	return margin + labelHeight + r.n.up() + r.n.down()
+}
+
+// draw draws the labeled row with its top left corner at (0, y) using SVG.
+func (r *row) draw(w io.Writer, y int) {
+	fmt.Fprintf(w, "<text class=\"label\" x=\"%d\" y=\"%d\">%s</text>\n", margin, y+margin, html.EscapeString(r.name))
+	x := margin
+	y += margin + labelHeight + r.n.up()
+	// start marker.
+	line(w, x, y-boxHeight/4, x, y+boxHeight/4)
+	line(w, x, y, x+markerWidth, y)
+	r.n.draw(w, x+markerWidth, y)
+	// end marker.
+	x += markerWidth + r.n.width()
+	line(w, x, y, x+markerWidth, y)
+	line(w, x+markerWidth, y-boxHeight/4, x+markerWidth, y+boxHeight/4)
+}
+
+// style is the CSS style of railroad diagrams.
+const style = `
+path { fill: none; stroke: #333; stroke-width: 2; }
+rect { fill: #ffd; stroke: #333; stroke-width: 2; }
+rect.nonterminal { fill: #def; }
+text { font-family: monospace; font-size: 14px; text-anchor: middle; dominant-baseline: central; }
+text.label { font-weight: bold; text-anchor: start; }
+`
+
+// writeSVG writes the given rows of railroad diagrams as a single SVG image,
+// with each row stacked below the previous.
+func writeSVG(w io.Writer, rs []*row) {
+	width, height := 0, margin
+	for _, r := range rs {
+		width = max(width, r.width())
+		height += r.height()
+	}
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	fmt.Fprintf(w, "<style>%s</style>\n", style)
+	y := 0
+	for _, r := range rs {
+		fmt.Fprintf(w, "<g>\n")
+		r.draw(w, y)
+		fmt.Fprintf(w, "</g>\n")
+		y += r.height()
+	}
+	fmt.Fprintln(w, "</svg>")
+}
@@ -0,0 +1,235 @@
+// The shellcomplete tool generates bash and zsh completion scripts of commands
+// from EBNF grammars describing their command line syntax.
+//
+// The grammar describes the arguments following the command name, with the
+// arguments separated by whitespace as matched by the skip production rule.
+//
+//	Command = "status" | "commit" [ "-m" message ] | "checkout" branch .
+//	branch  = letter { letter } .
+//	skip    = " " .
+//
+// The generated scripts (_NAME.zsh and NAME.bash) invoke shellcomplete in
+// completion mode (-complete) with the arguments preceding the word being
+// completed, which outputs the tokens of the grammar expected after the
+// arguments using the suggest package, one per line. The shell filters the
+// candidates by the partially typed word.
+//
+// Where a dynamic production rule (e.g. identifier) may begin after the
+// arguments, the output of the user-specified dynamic completion command is
+// included as candidates.
+//
+//	shellcomplete -name git -dynamic-rule branch -dynamic-cmd 'git branch --format="%(refname:short)"' git.ebnf
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/suggest"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+func usage() {
+	const use = `
+Usage: shellcomplete [OPTION]... GRAMMAR
+       shellcomplete -complete [OPTION]... GRAMMAR [ARG]...
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Command name.
+		name string
+		// Start production rule.
+		start string
+		// Output directory of completion scripts.
+		outputDir string
+		// Comma-separated list of dynamic production rules.
+		dynamicRules string
+		// Shell command outputting dynamic completion candidates.
+		dynamicCmd string
+		// Output completion candidates of the arguments.
+		complete bool
+	)
+	flag.StringVar(&name, "name", "", "command name (default base name of grammar)")
+	flag.StringVar(&start, "start", "", "start production rule (default first syntactic production)")
+	flag.StringVar(&outputDir, "o", ".", "output directory of completion scripts")
+	flag.StringVar(&dynamicRules, "dynamic-rule", "identifier", "comma-separated list of production rules completed by the dynamic completion command")
+	flag.StringVar(&dynamicCmd, "dynamic-cmd", "", "shell command outputting dynamic completion candidates, one per line")
+	flag.BoolVar(&complete, "complete", false, "output completion candidates following the given arguments (used by completion scripts)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 || (!complete && flag.NArg() != 1) {
+		flag.Usage()
+		os.Exit(1)
+	}
+	grammarPath := flag.Arg(0)
+	grammar, err := analysis.ParseFile(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if len(start) == 0 {
+		start = analysis.FirstSyntactic(grammar)
+	}
+	if _, ok := grammar[start]; !ok {
+		log.Fatalf("unable to locate start production rule %q in grammar %q", start, grammarPath)
+	}
+	var dynamic []string
+	for _, rule := range strings.Split(dynamicRules, ",") {
+		if rule = strings.TrimSpace(rule); len(rule) > 0 {
+			dynamic = append(dynamic, rule)
+		}
+	}
+
+	// Output completion candidates.
+	if complete {
+		candidates, err := completions(grammar, start, flag.Args()[1:], dynamic, dynamicCmd)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		bw := bufio.NewWriter(os.Stdout)
+		defer bw.Flush()
+		for _, candidate := range candidates {
+			fmt.Fprintln(bw, candidate)
+		}
+		return
+	}
+
+	// Generate completion scripts.
+	if len(name) == 0 {
+		name = strings.TrimSuffix(filepath.Base(grammarPath), filepath.Ext(grammarPath))
+	}
+	absPath, err := filepath.Abs(grammarPath)
+	if err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	// Completion command invoked by the completion scripts.
+	cmd := []string{"shellcomplete", "-complete", "-start", start, "-dynamic-rule", strings.Join(dynamic, ",")}
+	if len(dynamicCmd) > 0 {
+		cmd = append(cmd, "-dynamic-cmd", dynamicCmd)
+	}
+	cmd = append(cmd, absPath)
+	var quoted []string
+	for _, arg := range cmd {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	completeCmd := strings.Join(quoted, " ")
+	scripts := map[string]string{
+		"_" + name + ".zsh": zshScript(name, completeCmd),
+		name + ".bash":      bashScript(name, completeCmd),
+	}
+	for filename, script := range scripts {
+		path := filepath.Join(outputDir, filename)
+		if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+	}
+}
+
+// completions returns the completion candidates following the given arguments;
+// the tokens expected after the arguments, followed by the output of the dynamic
+// completion command if a dynamic production rule may begin after the
+// arguments.
+func completions(grammar ebnf.Grammar, start string, args []string, dynamic []string, dynamicCmd string) ([]string, error) {
+	// Arguments are separated by whitespace, including the argument being
+	// completed.
+	input := []byte(strings.Join(append(args, ""), " "))
+	if len(args) == 0 {
+		input = nil
+	}
+	var candidates []string
+	for _, sug := range suggest.Suggest(grammar, start, input, len(input)) {
+		candidates = append(candidates, sug.Text)
+	}
+	if len(dynamicCmd) == 0 {
+		return candidates, nil
+	}
+	for _, name := range suggest.Productions(grammar, start, input, len(input)) {
+		if !contains(dynamic, name) {
+			continue
+		}
+		out, err := exec.Command("sh", "-c", dynamicCmd).Output()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to run dynamic completion command %q", dynamicCmd)
+		}
+		for _, line := range strings.Split(string(bytes.TrimSpace(out)), "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				candidates = append(candidates, line)
+			}
+		}
+		break
+	}
+	return candidates, nil
+}
+
+// bashScript returns the bash completion script of the given command, using the
+// given completion command to output completion candidates.
+func bashScript(name, completeCmd string) string {
+	const format = `# bash completion of %[1]s; generated by shellcomplete.
+
+_%[2]s() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	local IFS=$'\n'
+	COMPREPLY=($(compgen -W "$(%[3]s "${COMP_WORDS[@]:1:COMP_CWORD-1}")" -- "$cur"))
+}
+
+complete -F _%[2]s %[1]s
+`
+	return fmt.Sprintf(format, name, funcName(name), completeCmd)
+}
+
+// zshScript returns the zsh completion script of the given command, using the
+// given completion command to output completion candidates.
+func zshScript(name, completeCmd string) string {
+	const format = `#compdef %[1]s
+# zsh completion of %[1]s; generated by shellcomplete.
+
+local -a candidates
+candidates=("${(@f)$(%[2]s "${(@)words[2,CURRENT-1]}")}")
+compadd -a candidates
+`
+	return fmt.Sprintf(format, name, completeCmd)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// shellQuote returns the given string quoted for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// funcName returns a shell function name based on the given command name.
+func funcName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// contains reports whether the given list of strings contains s.
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"golang.org/x/exp/ebnf"
+)
+
+// position is a zero-based position in a text document.
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// span is a range in a text document.
+type span struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// diagnostic is an error of a text document.
+type diagnostic struct {
+	Range    span   `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// Diagnostic severities.
+const (
+	severityError = 1
+)
+
+// diagnostics returns the syntax and validation errors of the given EBNF
+// grammar.
+func diagnostics(text, skipRule string) []diagnostic {
+	grammar, err := ebnf.Parse("", strings.NewReader(text))
+	if err != nil {
+		return errorDiagnostics(err)
+	}
+	start := analysis.FirstSyntactic(grammar)
+	if len(start) == 0 {
+		return []diagnostic{newDiagnostic(position{}, "unable to locate first syntactic production rule (capital letter)")}
+	}
+	if err := analysis.Verify(grammar, skipRule, start); err != nil {
+		return errorDiagnostics(err)
+	}
+	return nil
+}
+
+// reError matches error messages with a position prefix, as produced by the
+// ebnf package (e.g. "<input>:3:7: msg" for grammars without filename).
+var reError = regexp.MustCompile(`^(?:[^:]*:)?([0-9]+):([0-9]+): (.*)$`)
+
+// errorDiagnostics returns the diagnostics of the given error of the ebnf
+// package.
+func errorDiagnostics(err error) []diagnostic {
+	var diags []diagnostic
+	for _, e := range analysis.SplitErrors(err) {
+		msg := e.Error()
+		var pos position
+		if m := reError.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			col, _ := strconv.Atoi(m[2])
+			pos = position{Line: line - 1, Character: col - 1}
+			msg = m[3]
+		}
+		diags = append(diags, newDiagnostic(pos, msg))
+	}
+	return diags
+}
+
+// newDiagnostic returns a new error diagnostic at the given position.
+func newDiagnostic(pos position, msg string) diagnostic {
+	return diagnostic{
+		Range:    span{Start: pos, End: pos},
+		Severity: severityError,
+		Source:   "speak",
+		Message:  msg,
+	}
+}
+
+// definition returns the EBNF definition of the production name at the given
+// position of the EBNF grammar.
+func definition(text string, pos position) (string, bool) {
+	lines := strings.Split(text, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return "", false
+	}
+	name := identAt([]rune(lines[pos.Line]), pos.Character)
+	if len(name) == 0 {
+		return "", false
+	}
+	grammar, _ := ebnf.Parse("", strings.NewReader(text))
+	prod, ok := grammar[name]
+	if !ok {
+		return "", false
+	}
+	return format.SprintProd(prod), true
+}
+
+// offset returns the byte offset of the given position in the text document.
+func offset(text string, pos position) (int, bool) {
+	lines := strings.SplitAfter(text, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return 0, false
+	}
+	off := 0
+	for _, line := range lines[:pos.Line] {
+		off += len(line)
+	}
+	line := []rune(strings.TrimSuffix(lines[pos.Line], "\n"))
+	if pos.Character < 0 || pos.Character > len(line) {
+		return 0, false
+	}
+	return off + len(string(line[:pos.Character])), true
+}
+
+// identAt returns the identifier at the given column of the line, or the empty
+// string if no identifier is present.
+func identAt(line []rune, col int) string {
+	if col < 0 || col > len(line) {
+		return ""
+	}
+	start, end := col, col
+	for start > 0 && isIdent(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isIdent(line[end]) {
+		end++
+	}
+	return string(line[start:end])
+}
+
+// isIdent reports whether the given rune may be part of an identifier.
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
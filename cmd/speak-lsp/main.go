@@ -0,0 +1,426 @@
+// The speak-lsp tool is a language server for EBNF grammars, implementing a
+// minimal subset of the Language Server Protocol over standard input and
+// output.
+//
+// Supported features:
+//
+//   - diagnostics of syntax and validation errors (textDocument/publishDiagnostics)
+//   - production rule definitions on hover (textDocument/hover)
+//   - completion of input documents (textDocument/completion)
+//
+// When a language grammar is specified with -grammar, documents other than EBNF
+// grammars (.ebnf) are considered input documents of the language, for which
+// completion suggestions are provided based on the grammar.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/suggest"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+var (
+	// dbg is a logger with the "speak-lsp:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("speak-lsp:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Usage: speak-lsp [OPTION]...
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Output debug messages.
+		debug bool
+		// Skip production rule.
+		skipRule string
+		// Path to EBNF grammar of input documents.
+		grammarPath string
+		// Start production rule of input documents.
+		start string
+	)
+	flag.BoolVar(&debug, "v", false, "output debug messages to standard error")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.StringVar(&grammarPath, "grammar", "", "path to EBNF grammar of input documents; enables completion")
+	flag.StringVar(&start, "start", "", "start production rule of input documents (default first syntactic production)")
+	flag.Usage = usage
+	flag.Parse()
+	if debug {
+		dbg.SetOutput(os.Stderr)
+	}
+
+	// Serve language server requests.
+	s := newServer(os.Stdin, os.Stdout, skipRule)
+	if len(grammarPath) > 0 {
+		grammar, err := analysis.ParseFile(grammarPath)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		if len(start) == 0 {
+			start = analysis.FirstSyntactic(grammar)
+		}
+		if _, ok := grammar[start]; !ok {
+			log.Fatalf("unable to locate start production rule %q in grammar %q", start, grammarPath)
+		}
+		s.grammar, s.start = grammar, start
+	}
+	if err := s.serve(); err != nil {
+		log.Fatalf("%+v", err)
+	}
+}
+
+// server is a language server of EBNF grammars.
+type server struct {
+	// Reader of client messages.
+	r *bufio.Reader
+	// Writer of server messages.
+	w io.Writer
+	// Skip production rule.
+	skipRule string
+	// EBNF grammar of input documents; nil if not specified.
+	grammar ebnf.Grammar
+	// Start production rule of input documents.
+	start string
+	// Text of open documents, indexed by URI.
+	docs map[string]string
+	// Shutdown request received.
+	shutdown bool
+}
+
+// newServer returns a new language server reading client messages from r and
+// writing server messages to w.
+func newServer(r io.Reader, w io.Writer, skipRule string) *server {
+	return &server{
+		r:        bufio.NewReader(r),
+		w:        w,
+		skipRule: skipRule,
+		docs:     make(map[string]string),
+	}
+}
+
+// message is a JSON-RPC message.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+// responseError is a JSON-RPC response error.
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// serve handles client messages until the exit notification is received or
+// the client closes the connection.
+func (s *server) serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		dbg.Println("method:", msg.Method)
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				os.Exit(1)
+			}
+			return nil
+		}
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			// notification; no response.
+			continue
+		}
+		resp := &message{
+			ID:     msg.ID,
+			Result: result,
+			Error:  rerr,
+		}
+		if rerr == nil && result == nil {
+			// null result.
+			resp.Result = json.RawMessage("null")
+		}
+		if err := s.write(resp); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+}
+
+// handle handles the given client message, and returns the result of requests.
+func (s *server) handle(msg *message) (interface{}, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		capabilities := map[string]interface{}{
+			// full document sync.
+			"textDocumentSync": 1,
+			"hoverProvider":    true,
+		}
+		if s.grammar != nil {
+			capabilities["completionProvider"] = map[string]interface{}{}
+		}
+		result := map[string]interface{}{
+			"capabilities": capabilities,
+			"serverInfo": map[string]string{
+				"name": "speak-lsp",
+			},
+		}
+		return result, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			// full document sync; the last change holds the entire document.
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.publish(params.TextDocument.URI, nil)
+		return nil, nil
+	case "textDocument/hover":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Position position `json:"position"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(params.TextDocument.URI, params.Position), nil
+	case "textDocument/completion":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Position position `json:"position"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.completion(params.TextDocument.URI, params.Position), nil
+	default:
+		if msg.ID == nil {
+			// ignore unsupported notifications.
+			return nil, nil
+		}
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not supported", msg.Method)}
+	}
+}
+
+// update updates the text of the given document and publishes its
+// diagnostics.
+func (s *server) update(uri, text string) {
+	s.docs[uri] = text
+	if s.isInput(uri) {
+		// input documents are not EBNF grammars.
+		return
+	}
+	s.publish(uri, diagnostics(text, s.skipRule))
+}
+
+// isInput reports whether the given document is an input document of the
+// language grammar, rather than an EBNF grammar.
+func (s *server) isInput(uri string) bool {
+	return s.grammar != nil && !strings.HasSuffix(uri, ".ebnf")
+}
+
+// publish publishes the diagnostics of the given document.
+func (s *server) publish(uri string, diags []diagnostic) {
+	if diags == nil {
+		diags = []diagnostic{}
+	}
+	params := map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	}
+	buf, err := json.Marshal(params)
+	if err != nil {
+		log.Printf("%+v", errors.WithStack(err))
+		return
+	}
+	msg := &message{
+		Method: "textDocument/publishDiagnostics",
+		Params: buf,
+	}
+	if err := s.write(msg); err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// hover returns the hover result of the given position in the document, or
+// nil if the position is not at a production name.
+func (s *server) hover(uri string, pos position) interface{} {
+	text, ok := s.docs[uri]
+	if !ok {
+		return nil
+	}
+	def, ok := definition(text, pos)
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"contents": map[string]string{
+			"kind":  "markdown",
+			"value": "```ebnf\n" + def + "\n```",
+		},
+	}
+}
+
+// completionItem is a completion suggestion.
+type completionItem struct {
+	Label  string `json:"label"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// completion returns the completion items of the given position in the input
+// document.
+func (s *server) completion(uri string, pos position) []completionItem {
+	items := []completionItem{}
+	text, ok := s.docs[uri]
+	if !ok || !s.isInput(uri) {
+		return items
+	}
+	cursor, ok := offset(text, pos)
+	if !ok {
+		return items
+	}
+	for _, sug := range suggest.Suggest(s.grammar, s.start, []byte(text), cursor) {
+		item := completionItem{
+			Label:  sug.Text,
+			Detail: sug.Production,
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// invalidParams returns an invalid params response error.
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// read reads a client message.
+func (s *server) read() (*message, error) {
+	// Read header.
+	length := -1
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && len(line) == 0 {
+				return nil, io.EOF
+			}
+			return nil, errors.WithStack(err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			break
+		}
+		const prefix = "Content-Length:"
+		if strings.HasPrefix(line, prefix) {
+			n, err := strconv.Atoi(strings.TrimSpace(line[len(prefix):]))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			length = n
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	// Read content.
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	msg := &message{}
+	if err := json.Unmarshal(buf, msg); err != nil {
+		resp := &message{
+			Error: &responseError{Code: codeParseError, Message: err.Error()},
+		}
+		if err := s.write(resp); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// skip invalid message.
+		return s.read()
+	}
+	return msg, nil
+}
+
+// write writes a server message.
+func (s *server) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(buf), buf); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
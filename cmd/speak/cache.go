@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/format"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// grammarCache is a pre-parsed grammar, as stored in grammar cache files. The
+// AST of golang.org/x/exp/ebnf is not gob-friendly (e.g. interface types of
+// expressions), so production rules are stored in EBNF notation and re-parsed
+// when decoded.
+type grammarCache struct {
+	// Start production rule.
+	Start string
+	// Production rules in EBNF notation, in order of production name.
+	Prods []string
+}
+
+// parseCachedGrammar parses the given EBNF grammar and determines its start
+// production rule, using the given grammar cache file. The grammar is decoded
+// from the cache file if newer than the grammar file, and otherwise parsed and
+// encoded to the cache file. Note, only the modification time of the root
+// grammar file is taken into account, not of included grammars.
+func parseCachedGrammar(grammarPath, cachePath string) (ebnf.Grammar, string, error) {
+	grammarInfo, err := os.Stat(grammarPath)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	if cacheInfo, err := os.Stat(cachePath); err == nil && cacheInfo.ModTime().After(grammarInfo.ModTime()) {
+		dbg.Println("grammar cache:", cachePath)
+		return decodeGrammar(cachePath)
+	}
+	grammar, start, err := parseGrammar(grammarPath)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	if err := encodeGrammar(cachePath, grammar, start); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	return grammar, start, nil
+}
+
+// encodeGrammar encodes the given grammar and start production rule to the
+// given grammar cache file.
+func encodeGrammar(cachePath string, grammar ebnf.Grammar, start string) error {
+	cache := grammarCache{Start: start}
+	for _, name := range analysis.Names(grammar) {
+		cache.Prods = append(cache.Prods, format.SprintProd(grammar[name]))
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(cache); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ioutil.WriteFile(cachePath, buf.Bytes(), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeGrammar decodes the grammar and start production rule of the given
+// grammar cache file.
+func decodeGrammar(cachePath string) (ebnf.Grammar, string, error) {
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	defer f.Close()
+	var cache grammarCache
+	if err := gob.NewDecoder(f).Decode(&cache); err != nil {
+		return nil, "", errors.Wrapf(err, "unable to decode grammar cache %q", cachePath)
+	}
+	src := strings.Join(cache.Prods, "\n")
+	grammar, err := ebnf.Parse(cachePath, strings.NewReader(src))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "unable to parse grammar of grammar cache %q", cachePath)
+	}
+	if _, ok := grammar[cache.Start]; !ok {
+		return nil, "", errors.Errorf("unable to locate start production rule %q in grammar cache %q", cache.Start, cachePath)
+	}
+	return grammar, cache.Start, nil
+}
+
+// loadFirstSets returns the first runes of each production rule of the given
+// grammar, using the given first set file. The first sets are decoded from the
+// first set file if newer than the grammar file (unless recompute is set), and
+// otherwise computed from the given FIRST sets and encoded to the first set
+// file. Note, only the modification time of the root grammar file is taken into
+// account, not of included grammars.
+func loadFirstSets(grammarPath, firstSetPath string, grammar ebnf.Grammar, first map[string]analysis.Set, recompute bool) (map[string]map[rune]bool, error) {
+	grammarInfo, err := os.Stat(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if firstSetInfo, err := os.Stat(firstSetPath); err == nil && !recompute && firstSetInfo.ModTime().After(grammarInfo.ModTime()) {
+		dbg.Println("first set file:", firstSetPath)
+		return decodeFirstSets(firstSetPath, grammar)
+	}
+	firstRunes := make(map[string]map[rune]bool)
+	for name := range grammar {
+		firstRunes[name] = runeSet(first[name])
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(firstRunes); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ioutil.WriteFile(firstSetPath, buf.Bytes(), 0644); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return firstRunes, nil
+}
+
+// runeSet returns the first runes of the terminals of the given FIRST set; the
+// first rune of tokens, and every rune of character ranges.
+func runeSet(first analysis.Set) map[rune]bool {
+	m := make(map[rune]bool)
+	for t := range first {
+		switch {
+		case t == analysis.EOF:
+			// end of input.
+		case len(t.Token) > 0:
+			r, _ := utf8.DecodeRuneInString(t.Token)
+			m[r] = true
+		default:
+			for r := t.Begin; r <= t.End; r++ {
+				m[r] = true
+			}
+		}
+	}
+	return m
+}
+
+// decodeFirstSets decodes the first runes of each production rule of the given
+// first set file, and verifies that it holds the production rules of the
+// grammar.
+func decodeFirstSets(firstSetPath string, grammar ebnf.Grammar) (map[string]map[rune]bool, error) {
+	f, err := os.Open(firstSetPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	var first map[string]map[rune]bool
+	if err := gob.NewDecoder(f).Decode(&first); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode first set file %q", firstSetPath)
+	}
+	for name := range grammar {
+		if _, ok := first[name]; !ok {
+			return nil, errors.Errorf("unable to locate first set of production rule %q in first set file %q; try -recompute-first-sets", name, firstSetPath)
+		}
+	}
+	return first, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/eval"
+)
+
+func TestFirstSetFile(t *testing.T) {
+	golden := []struct {
+		grammar string
+		input   string
+	}{
+		// empty token.
+		{grammar: `A = e "x" . e = "" .`, input: "x"},
+		// empty production.
+		{grammar: `A = "x" [ e ] . e = .`, input: "x"},
+		// nullable chain.
+		{grammar: `A = b c "z" . b = [ "y" ] . c = { "w" } .`, input: "z"},
+		{grammar: `A = b c "z" . b = [ "y" ] . c = { "w" } .`, input: "ywwz"},
+		// recursion.
+		{grammar: `A = "(" A ")" | "x" .`, input: "((x))"},
+	}
+	for _, g := range golden {
+		dir := t.TempDir()
+		grammarPath := filepath.Join(dir, "test.ebnf")
+		if err := ioutil.WriteFile(grammarPath, []byte(g.grammar), 0644); err != nil {
+			t.Fatalf("unable to write grammar; %v", err)
+		}
+		grammar, _, err := parseGrammar(grammarPath)
+		if err != nil {
+			t.Fatalf("%q: unable to parse grammar; %+v", g.grammar, err)
+		}
+		// Parse without first set file.
+		conf := &config{
+			Config: eval.Config{
+				SkipRule: "skip",
+				Tree:     true,
+				First:    analysis.First(grammar),
+				Nullable: analysis.Nullable(grammar),
+			},
+			starts: []string{"A"},
+		}
+		_, want, wantErrs, _, err := parse(grammar, []byte(g.input), conf)
+		if err != nil {
+			t.Fatalf("%q: unable to parse %q; %+v", g.grammar, g.input, err)
+		}
+		if len(wantErrs) > 0 {
+			t.Errorf("%q: unable to parse %q without first set file; %v", g.grammar, g.input, wantErrs[0])
+			continue
+		}
+		// Parse with first set file; computed and saved, and then loaded.
+		// ensure that the first set file is newer than the grammar.
+		past := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(grammarPath, past, past); err != nil {
+			t.Fatalf("unable to set modification time of grammar; %v", err)
+		}
+		firstSetPath := filepath.Join(dir, "first.gob")
+		for _, recompute := range []bool{true, false} {
+			firstRunes, err := loadFirstSets(grammarPath, firstSetPath, grammar, conf.First, recompute)
+			if err != nil {
+				t.Fatalf("%q: unable to load first sets; %+v", g.grammar, err)
+			}
+			conf := *conf
+			conf.FirstRunes = firstRunes
+			_, got, errs, _, err := parse(grammar, []byte(g.input), &conf)
+			if err != nil {
+				t.Fatalf("%q: unable to parse %q; %+v", g.grammar, g.input, err)
+			}
+			if len(errs) > 0 {
+				t.Errorf("%q: unable to parse %q with first set file (recompute=%v); %v", g.grammar, g.input, recompute, errs[0])
+				continue
+			}
+			if got.Start != want.Start || got.End != want.End {
+				t.Errorf("%q: root node mismatch of %q with first set file (recompute=%v); expected %d-%d, got %d-%d", g.grammar, g.input, recompute, want.Start, want.End, got.Start, got.End)
+			}
+		}
+	}
+}
@@ -1,24 +1,38 @@
-// TODO: optimize by calculating first sets.
-
 // Speak parses input by runtime evaluation of language grammars expressed in
 // EBNF.
 package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/mewkiz/pkg/ioutilx"
 	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/eval"
+	"github.com/mewmew/speak/format"
 	"github.com/pkg/errors"
 	"golang.org/x/exp/ebnf"
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
@@ -44,372 +58,599 @@ func main() {
 	var (
 		// path to EBNF grammar
 		grammarPath string
-		// Start production rule.
+		// Comma-separated list of start production rules.
 		start string
+		// Skip production rule.
+		skipRule string
+		// Timeout of each parse.
+		timeout time.Duration
+		// Number of benchmark runs.
+		bench int
+		// Output parse errors in JSON format.
+		jsonErrors bool
+		// Path to parse trace output.
+		traceFile string
+		// Explain parse errors in natural language.
+		explainErrors bool
+		// Maximum production rule nesting depth.
+		maxDepth int
+		// Output format of parse trees (tree, json, sexpr or none).
+		outputFormat string
+		// Start byte offset of the input range to parse.
+		from int
+		// End byte offset of the input range to parse; -1 for end of input.
+		to int
+		// Unicode normalization form of input (NFC, NFD, NFKC or NFKD).
+		unicodeNormalize string
+		// Succeed if the grammar matches a prefix of the input.
+		partial bool
+		// Recover from parse errors in sequences.
+		recovery bool
+		// Report the effectiveness of first-set guided alternative selection.
+		profileGrammar bool
+		// Parse all files of the given directories; succeed if all match.
+		matchAll bool
+		// Parse all files of the given directories; succeed if any match.
+		matchAny bool
+		// File extension of input files in directories.
+		ext string
+		// Path to grammar cache file.
+		cacheGrammar string
+		// Print matched text of production rules.
+		echo bool
+		// Re-parse when the grammar or input files change.
+		watchFiles bool
+		// Comma-separated list of production rules to inline.
+		inline string
+		// Print content hash of grammar.
+		grammarHash bool
+		// Path to source map output.
+		sourceMapFile string
+		// Stream parse events instead of building parse trees.
+		stream bool
+		// Reorder alternatives by decreasing first-set size.
+		normalizeAlternatives bool
+		// Path to first set file.
+		firstSetFile string
+		// Recompute first sets regardless of the first set file timestamp.
+		recomputeFirstSets bool
+		// Path to output map of input offsets to production names.
+		outputMap string
 	)
 	flag.StringVar(&grammarPath, "grammar", "grammar.ebnf", "path to EBNF grammar")
-	flag.StringVar(&start, "start", "", "start production rule")
+	flag.StringVar(&start, "start", "", "start production rule; a comma-separated list of start production rules are tried in order")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.DurationVar(&timeout, "timeout", 0, "abort parse after the given duration (e.g. 5s); 0 disables timeout")
+	flag.IntVar(&bench, "bench", 0, "parse a single input file the given number of times and report throughput")
+	flag.BoolVar(&jsonErrors, "json-errors", false, "output parse errors in JSON format to standard output")
+	flag.IntVar(&maxDepth, "max-depth", 10000, "maximum production rule nesting depth; 0 disables limit")
+	flag.BoolVar(&explainErrors, "explain", false, "explain parse errors in natural language on standard error")
+	flag.StringVar(&traceFile, "trace-file", "", "write parse trace as newline-delimited JSON to the given path")
+	flag.StringVar(&outputFormat, "output-format", "none", "output format of parse trees (tree, json, sexpr or none)")
+	flag.IntVar(&from, "from", 0, "start byte offset of the input range to parse")
+	flag.IntVar(&to, "to", -1, "end byte offset of the input range to parse; -1 for end of input")
+	flag.StringVar(&unicodeNormalize, "unicode-normalize", "", "Unicode normalization form applied to input before parsing (NFC, NFD, NFKC or NFKD)")
+	flag.BoolVar(&partial, "partial", false, "succeed if the grammar matches a prefix of the input, and report the number of bytes consumed")
+	flag.BoolVar(&recovery, "recover", false, "recover from parse errors in sequences by skipping input up to the follow set of the production")
+	flag.BoolVar(&profileGrammar, "profile-grammar", false, "report the percentage of alternative evaluations pruned by first-set lookups on standard error")
+	flag.BoolVar(&matchAll, "match-all", false, "parse the files of the given directories recursively, and succeed if all files match")
+	flag.BoolVar(&matchAny, "match-any", false, "parse the files of the given directories recursively, and succeed if at least one file matches")
+	flag.StringVar(&ext, "ext", "", "file extension of input files in directories (e.g. .c); empty matches all files")
+	flag.StringVar(&cacheGrammar, "cache-grammar", "", "path to grammar cache file; used if newer than the grammar, and otherwise updated")
+	flag.StringVar(&firstSetFile, "first-set-file", "", "path to first set file, used to reject input which cannot begin production rules; loaded if newer than the grammar, and otherwise computed and saved")
+	flag.BoolVar(&recomputeFirstSets, "recompute-first-sets", false, "recompute first sets and save them to the first set file, regardless of its timestamp")
+	flag.BoolVar(&echo, "echo", false, "print the matched text of each production rule of the parse tree")
+	flag.BoolVar(&normalizeAlternatives, "normalize-alternatives", false, "reorder alternatives by decreasing first-set size before evaluation, as a heuristic for greedy matching")
+	flag.BoolVar(&stream, "stream", false, "write open and close events of production rules as JSON lines to standard output, instead of building parse trees; events undone by backtracking are withdrawn by retract events")
+	flag.StringVar(&outputMap, "output-map", "", "write the start offset and innermost production rule of each terminal match as CSV to the given path, after a successful parse of a single input file")
+	flag.StringVar(&sourceMapFile, "source-map", "", "write source maps of printed parse trees (tree or sexpr) as newline-delimited JSON to the given path")
+	flag.BoolVar(&grammarHash, "grammar-hash", false, "print the SHA-256 hash of the canonical EBNF representation of the grammar and exit")
+	flag.StringVar(&inline, "inline", "", "comma-separated list of production rules inlined into their callers before evaluation")
+	flag.BoolVar(&watchFiles, "watch", false, "re-parse when the grammar or input files change (polled every 500ms); exit with Ctrl-C")
 	flag.Usage = usage
 	flag.Parse()
+	if !analysis.IsLexical(skipRule) {
+		log.Fatalf("invalid skip rule %q; skip rule must be a lexical production (lowercase name)", skipRule)
+	}
+	switch outputFormat {
+	case "tree", "json", "sexpr", "none":
+		// valid output format.
+	default:
+		log.Fatalf("invalid output format %q; expected tree, json, sexpr or none", outputFormat)
+	}
+	if _, ok := normForms[unicodeNormalize]; !ok && len(unicodeNormalize) > 0 {
+		log.Fatalf("invalid Unicode normalization form %q; expected NFC, NFD, NFKC or NFKD", unicodeNormalize)
+	}
+	if matchAll && matchAny {
+		log.Fatalf("invalid combination of -match-all and -match-any; expected at most one")
+	}
+
+	// Re-run parser on changes to grammar and input files.
+	if watchFiles {
+		paths := append([]string{grammarPath}, flag.Args()...)
+		if err := watch(watchArgs(flag.CommandLine), paths); err != nil {
+			log.Fatalf("%+v", err)
+		}
+		return
+	}
 
 	// Parse and validate grammar.
-	grammar, firstProd, err := parseGrammar(grammarPath)
+	var (
+		grammar   ebnf.Grammar
+		firstProd string
+		err       error
+	)
+	if len(cacheGrammar) > 0 {
+		grammar, firstProd, err = parseCachedGrammar(grammarPath, cacheGrammar)
+	} else {
+		grammar, firstProd, err = parseGrammar(grammarPath)
+	}
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
-	if len(start) == 0 {
-		start = firstProd
+	if grammarHash {
+		fmt.Println(hashGrammar(grammar))
+		return
 	}
-	dbg.Println("start:", start)
-	// Remove skip before validate.
-	skip, ok := grammar["skip"]
-	// TODO: Remove skip production rules recursively before validate.
-	if ok {
-		delete(grammar, "skip")
+	starts := splitNames(start)
+	if len(starts) == 0 {
+		starts = []string{firstProd}
 	}
-	if err := ebnf.Verify(grammar, start); err != nil {
-		log.Fatalf("%+v", errors.WithStack(err))
+	dbg.Println("start:", starts)
+	dbg.Println("skip:", skipRule)
+	if err := analysis.Verify(grammar, skipRule, starts...); err != nil {
+		log.Fatalf("%+v", err)
 	}
-	// Add skip after validate.
-	if ok {
-		grammar["skip"] = skip
+	// Inline production rules after validate.
+	if names := splitNames(inline); len(names) > 0 {
+		if grammar, err = inlineGrammar(grammar, names); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	if normalizeAlternatives {
+		normalizeAlts(grammar)
 	}
 
-	// Parse input by runtime evaluation of the grammar.
-	for _, inputPath := range flag.Args() {
-		input, err := ioutilx.ReadFile(inputPath)
+	conf := &config{
+		Config: eval.Config{
+			SkipRule: skipRule,
+			MaxDepth: maxDepth,
+			Tree:     (outputFormat != "none" || echo) && bench == 0 && !stream,
+			From:     from,
+			Partial:  partial,
+			Recovery: recovery,
+			First:    analysis.First(grammar),
+			Nullable: analysis.Nullable(grammar),
+		},
+		starts:  starts,
+		timeout: timeout,
+	}
+	if len(firstSetFile) > 0 {
+		if conf.FirstRunes, err = loadFirstSets(grammarPath, firstSetFile, grammar, conf.First, recomputeFirstSets); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	if stream {
+		conf.Stream = eval.NewEventStream(os.Stdout)
+		defer conf.Stream.Flush()
+	}
+	if len(traceFile) > 0 {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		defer f.Close()
+		bw := bufio.NewWriter(f)
+		defer bw.Flush()
+		conf.Trace = bw
+	}
+
+	var sourceMaps *bufio.Writer
+	if len(sourceMapFile) > 0 {
+		f, err := os.Create(sourceMapFile)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		defer f.Close()
+		sourceMaps = bufio.NewWriter(f)
+		defer sourceMaps.Flush()
+	}
+
+	// Benchmark parser.
+	if bench > 0 {
+		if flag.NArg() != 1 {
+			log.Fatalf("invalid number of input files for benchmark; expected 1, got %d", flag.NArg())
+		}
+		input, err := readInput(flag.Arg(0), from, to, unicodeNormalize)
 		if err != nil {
 			log.Fatalf("%+v", err)
 		}
-		if err := speak(grammar, start, input); err != nil {
+		if err := benchmark(grammar, input, conf, bench); err != nil {
 			log.Fatalf("%+v", err)
 		}
+		return
 	}
-}
 
-// speak parses the given input by runtime evaluation of the grammar from the
-// start production rule.
-func speak(grammar ebnf.Grammar, start string, input []byte) error {
-	p := &parser{
-		grammar: grammar,
-		input:   input,
-	}
-	// Calculate first set.
-	//first := p.firstSet(grammar)
-	//pretty.Println("first:", first)
-	//return nil
-	ret := p.evalProd(p.grammar[start])
-	p.skip()
-	dbg.Println("speak:")
-	dbg.Printf("   speak.ret: %v", ret)
-	dbg.Printf("   speak.len: %v %v", len(input), p.pos)
-	return nil
-}
+	// Parse input files of directories, and report the number of files
+	// matched.
+	if matchAll || matchAny {
+		inputPaths, err := findInputs(flag.Args(), ext)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		passed := 0
+		for _, inputPath := range inputPaths {
+			input, err := readInput(inputPath, from, to, unicodeNormalize)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			_, _, errs, _, err := parse(grammar, input, conf)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if len(errs) > 0 {
+				fmt.Printf("FAIL %s:%v\n", inputPath, errs[0])
+				continue
+			}
+			passed++
+		}
+		fmt.Printf("%d/%d files passed\n", passed, len(inputPaths))
+		if (matchAll && passed < len(inputPaths)) || (matchAny && passed == 0) {
+			os.Exit(1)
+		}
+		return
+	}
 
-// parser holds the state of the EBNF grammar used for parsing.
-type parser struct {
-	// EBNF language grammar.
-	grammar ebnf.Grammar
-	// Input source.
-	input []byte
-	// Current position in input source.
-	pos int
-	// End of input has been reached.
-	eof bool
-	// Currently skipping whitespace and comments in evalExpr.
-	skipping bool
-}
+	if len(outputMap) > 0 {
+		if flag.NArg() != 1 {
+			log.Fatalf("invalid number of input files for -output-map; expected 1, got %d", flag.NArg())
+		}
+		f, err := os.Create(outputMap)
+		if err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		defer f.Close()
+		cw := csv.NewWriter(f)
+		defer cw.Flush()
+		if err := cw.Write([]string{"offset", "production"}); err != nil {
+			log.Fatalf("%+v", errors.WithStack(err))
+		}
+		conf.OutputMap = cw
+	}
 
-// skip evaluates the skip production rule to ignore whitespace and comments.
-func (p *parser) skip() {
-	if p.skipping {
-		return
+	// Parse input by runtime evaluation of the grammar.
+	failed := false
+	for _, inputPath := range flag.Args() {
+		input, err := readInput(inputPath, from, to, unicodeNormalize)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		start, root, errs, stats, err := parse(grammar, input, conf)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		if profileGrammar {
+			fmt.Fprintf(os.Stderr, "%s: first-set pruned %.0f%% of alternative evaluations (%d of %d)\n", inputPath, stats.Percent(), stats.Pruned, stats.Total)
+		}
+		if len(errs) == 0 {
+			if len(starts) > 1 {
+				fmt.Fprintf(os.Stderr, "%s: parsed as %s (offset %d to %d)\n", inputPath, start, root.Start, root.End)
+			}
+			if partial {
+				fmt.Fprintf(os.Stderr, "%s: consumed %d of %d bytes\n", inputPath, root.End-from, len(input)-from)
+			}
+			if stream {
+				// parse events have been streamed.
+				continue
+			}
+			if echo {
+				fmt.Print(echoTree(root))
+			}
+			var sm *SourceMap
+			if sourceMaps != nil {
+				sm = &SourceMap{}
+			}
+			if err := printTree(outputFormat, inputPath, input, root, sm); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			if sourceMaps != nil {
+				if err := writeSourceMap(sourceMaps, inputPath, sm); err != nil {
+					log.Fatalf("%+v", err)
+				}
+			}
+			continue
+		}
+		failed = true
+		if jsonErrors {
+			if err := printJSONErrors(inputPath, errs); err != nil {
+				log.Fatalf("%+v", err)
+			}
+			continue
+		}
+		if explainErrors {
+			for _, sentence := range eval.Explain(grammar, errs) {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", inputPath, sentence)
+			}
+			continue
+		}
+		for _, e := range errs {
+			log.Printf("%s:%v", inputPath, e)
+		}
 	}
-	p.skipping = true
-	if skip, ok := p.grammar["skip"]; ok {
-		dbg.Println("skip:", exprString(skip))
-		// record pos, and reset if no whitespace found.
-		for {
-			bak := p.pos
-			if !p.evalExpr(skip.Expr) {
-				// reset pos.
-				p.pos = bak
-				break
+	if failed {
+		if conf.Trace != nil {
+			if err := conf.Trace.Flush(); err != nil {
+				log.Fatalf("%+v", errors.WithStack(err))
+			}
+		}
+		if sourceMaps != nil {
+			if err := sourceMaps.Flush(); err != nil {
+				log.Fatalf("%+v", errors.WithStack(err))
+			}
+		}
+		if conf.Stream != nil {
+			if err := conf.Stream.Flush(); err != nil {
+				log.Fatalf("%+v", errors.WithStack(err))
+			}
+		}
+		if conf.OutputMap != nil {
+			conf.OutputMap.Flush()
+			if err := conf.OutputMap.Error(); err != nil {
+				log.Fatalf("%+v", errors.WithStack(err))
 			}
 		}
+		os.Exit(1)
 	}
-	p.skipping = false
 }
 
-func (p *parser) evalProd(x *ebnf.Production) bool {
-	dbg.Println("evalProd:", exprString(x))
-	ret := p.evalExpr(x.Expr)
-	dbg.Printf("   evalProd.ret: %v", ret)
-	return ret
+// config holds the configuration of the runtime parser.
+type config struct {
+	// Configuration of the runtime parser.
+	eval.Config
+	// Start production rules, tried in order.
+	starts []string
+	// Timeout of each parse; 0 disables timeout.
+	timeout time.Duration
 }
 
-func (p *parser) evalExpr(x ebnf.Expression) bool {
-	dbg.Println("evalExpr:", exprString(x))
-	// skip whitespace and comments in between expressions.
-	p.skip()
-	switch x := x.(type) {
-	case *ebnf.Production:
-		panic(fmt.Errorf("support for expression %T not yet implemented", x))
-	case ebnf.Alternative:
-		ret := p.evalAlt(x)
-		dbg.Printf("   evalExpr.evalAlt.ret: %v", ret)
-		return ret
-	case ebnf.Sequence:
-		ret := p.evalSeq(x)
-		dbg.Printf("   evalExpr.evalSeq.ret: %v", ret)
-		return ret
-	case *ebnf.Name:
-		ret := p.evalName(x)
-		dbg.Printf("   evalExpr.evalName.ret: %v", ret)
-		return ret
-	case *ebnf.Token:
-		ret := p.evalToken(x)
-		dbg.Printf("   evalExpr.evalToken.ret: %v", ret)
-		return ret
-	case *ebnf.Range:
-		ret := p.evalRange(x)
-		dbg.Printf("   evalExpr.evalRange.ret: %v", ret)
-		return ret
-	case *ebnf.Group:
-		ret := p.evalGroup(x)
-		dbg.Printf("   evalExpr.evalGroup.ret: %v", ret)
-		return ret
-	case *ebnf.Option:
-		ret := p.evalOpt(x)
-		dbg.Printf("   evalExpr.evalOpt.ret: %v", ret)
-		return ret
-	case *ebnf.Repetition:
-		ret := p.evalRep(x)
-		dbg.Printf("   evalExpr.evalRep.ret: %v", ret)
-		return ret
-	default:
-		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+// normForms maps from names to Unicode normalization forms.
+var normForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+// readInput reads the given input file, normalized to the given Unicode
+// normalization form (if non-empty), and truncated to the end offset of the
+// input range to parse (if non-negative). The input before the start offset is
+// kept, so that offsets, line and column numbers of parse errors and parse trees
+// are relative to the input file rather than to the input range. Offsets refer
+// to the normalized input.
+func readInput(inputPath string, from, to int, form string) ([]byte, error) {
+	input, err := ioutilx.ReadFile(inputPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(form) > 0 {
+		input = normForms[form].Bytes(input)
 	}
+	if to == -1 {
+		to = len(input)
+	}
+	if from < 0 || to < from || to > len(input) {
+		return nil, errors.Errorf("invalid input range [%d:%d] of %q; input is %d bytes long", from, to, inputPath, len(input))
+	}
+	return input[:to], nil
 }
 
-// evalAlt evaluates a list of alternative expressions. One must be valid.
-//
-//    x | y | z
-func (p *parser) evalAlt(x ebnf.Alternative) bool {
-	dbg.Println("evalAlt:", exprString(x))
-	// TODO: Figure out how to try handle multiple valid alternatives. Is this
-	// even needed?
-	for _, e := range x {
-		// record pos, and reset for invalid alternatives.
-		bak := p.pos
-		if p.evalExpr(e) {
-			return true
-		}
-		// reset pos.
-		p.pos = bak
-	}
-	return false
+// hashGrammar returns the hex encoded SHA-256 hash of the canonical EBNF
+// representation of the given grammar, with one production rule per line in
+// order of production name. The hash is stable across edits of whitespace and
+// comments, and across reordering of production rules.
+func hashGrammar(grammar ebnf.Grammar) string {
+	h := sha256.New()
+	for _, name := range analysis.Names(grammar) {
+		fmt.Fprintln(h, format.SprintProd(grammar[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-// evalSeq evaluates a list of sequential expressions. All must be valid.
-//
-//    x y z
-func (p *parser) evalSeq(x ebnf.Sequence) bool {
-	dbg.Println("evalSeq:", exprString(x))
-	for _, e := range x {
-		if !p.evalExpr(e) {
-			return false
+// splitNames returns the production names of the given comma-separated list.
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
 		}
 	}
-	return true
+	return names
 }
 
-// evalName evaluates a the expression of a production name. Must be valid.
-//
-//    foo
-func (p *parser) evalName(x *ebnf.Name) bool {
-	dbg.Println("evalName:", exprString(x))
-	prod := p.grammar[x.String]
-	return p.evalProd(prod)
+// inlineGrammar returns a copy of the grammar where references to the given
+// production rules are replaced by their expressions, to reduce the overhead
+// of evaluating small helper production rules. Inlined production rules are no
+// longer recorded in parse trees.
+func inlineGrammar(grammar ebnf.Grammar, names []string) (g ebnf.Grammar, err error) {
+	for _, name := range names {
+		if _, ok := grammar[name]; !ok {
+			return nil, errors.Errorf("unable to inline production rule %q; no such production rule", name)
+		}
+	}
+	// analysis.Inline panics on self-referential production rules.
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.Errorf("unable to inline production rules %q; %v", names, e)
+		}
+	}()
+	return analysis.Inline(grammar, names), nil
 }
 
-// evalToken evaluates a literal. Must be valid.
-//
-//    "foo"
-func (p *parser) evalToken(x *ebnf.Token) bool {
-	dbg.Println("evalToken:", exprString(x))
-	for _, q := range x.String {
-		r := p.nextRune()
-		if r == eof {
-			if !p.skipping {
-				warn.Printf("unexpected EOF when evaluating token %v", exprString(x))
+// normalizeAlts reorders the alternatives of each alternative expression of
+// the grammar by decreasing first-set size, as a heuristic for specificity;
+// alternatives of equal first-set size retain their grammar order. Note that
+// character ranges count as a single terminal of first sets.
+func normalizeAlts(grammar ebnf.Grammar) {
+	first, nullable := analysis.First(grammar), analysis.Nullable(grammar)
+	var walk func(x ebnf.Expression)
+	walk = func(x ebnf.Expression) {
+		switch x := x.(type) {
+		case nil:
+			// empty expression.
+		case ebnf.Alternative:
+			type alt struct {
+				expr ebnf.Expression
+				size int
 			}
-			return false
-		}
-		if r != q {
-			if !p.skipping {
-				warn.Printf("   mismatch %q (expected %q)", r, q)
+			alts := make([]alt, len(x))
+			for i, e := range x {
+				walk(e)
+				alts[i] = alt{expr: e, size: len(analysis.FirstExpr(e, first, nullable))}
 			}
-			return false
+			sort.SliceStable(alts, func(i, j int) bool {
+				return alts[i].size > alts[j].size
+			})
+			for i, a := range alts {
+				x[i] = a.expr
+			}
+		case ebnf.Sequence:
+			for _, e := range x {
+				walk(e)
+			}
+		case *ebnf.Name, *ebnf.Token, *ebnf.Range, *ebnf.Bad:
+			// nothing to do.
+		case *ebnf.Group:
+			walk(x.Body)
+		case *ebnf.Option:
+			walk(x.Body)
+		case *ebnf.Repetition:
+			walk(x.Body)
+		default:
+			panic(fmt.Errorf("support for expression %T not yet implemented", x))
 		}
-		dbg.Printf("   match %q", r)
 	}
-	return true
+	for _, prod := range grammar {
+		walk(prod.Expr)
+	}
 }
 
-// evalRange evaluates a range of characters. Must be valid.
-//
-//    a … z
-func (p *parser) evalRange(x *ebnf.Range) bool {
-	dbg.Println("evalRange:", exprString(x))
-	from, _ := utf8.DecodeRuneInString(x.Begin.String)
-	to, _ := utf8.DecodeRuneInString(x.End.String)
-	r := p.nextRune()
-	if r == eof {
-		if !p.skipping {
-			warn.Printf("unexpected EOF when evaluating range %v", exprString(x))
-		}
-		return false
-	}
-	ret := from <= r && r <= to
-	if ret {
-		dbg.Printf("   match: %q in %q … %q", r, from, to)
-	} else {
-		if !p.skipping {
-			warn.Printf("   mismatch: %q not in %q … %q", r, from, to)
+// findInputs returns the input files of the given paths, where directories are
+// walked recursively for files with the given file extension (or all files if
+// empty). Files of the given paths are included regardless of extension.
+func findInputs(paths []string, ext string) ([]string, error) {
+	var inputPaths []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(inputPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if inputPath == path || len(ext) == 0 || filepath.Ext(inputPath) == ext {
+				inputPaths = append(inputPaths, inputPath)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
 	}
-	return ret
-}
-
-// evalGroup evaluates a grouped expression. Must be valid.
-//
-//    ( body )
-func (p *parser) evalGroup(x *ebnf.Group) bool {
-	dbg.Println("evalGroup:", exprString(x))
-	return p.evalExpr(x.Body)
+	return inputPaths, nil
 }
 
-// evalOpt evaluates an optional expression. Must have zero or one valid
-// expressions.
-//
-//    [ body ]
-func (p *parser) evalOpt(x *ebnf.Option) bool {
-	dbg.Println("evalOpt:", exprString(x))
-	// store position and try to parse the optional.
-	bak := p.pos
-	// EOF is valid in option
-	if !p.eof && !p.evalExpr(x.Body) {
-		// invalid body is valid in option
-		// reset position
-		p.pos = bak
-	}
-	return true
+// printJSONErrors prints the parse errors of the given input file in JSON
+// format to standard output.
+func printJSONErrors(inputPath string, errs []eval.ParseError) error {
+	v := struct {
+		Path   string            `json:"path"`
+		Errors []eval.ParseError `json:"errors"`
+	}{
+		Path:   inputPath,
+		Errors: errs,
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Println(string(buf))
+	return nil
 }
 
-// evalRep evaluates a repeated expression. Must have zero or more valid
-// expressions.
+// parse parses the given input by runtime evaluation of the grammar, aborting
+// the parse on interrupt or after the configured timeout (if non-zero).
 //
-//    { body }
-func (p *parser) evalRep(x *ebnf.Repetition) bool {
-	dbg.Println("evalRep:", exprString(x))
-	// EOF is valid in repetition
-	for !p.eof {
-		// store position and try to parse a repetition.
-		bak := p.pos
-		fmt.Println("bak:", bak)
-		if !p.evalExpr(x.Body) {
-			// invalid body is valid in repetition
-			// reset position
-			fmt.Println("p.pos:", p.pos)
-			p.pos = bak
-			break
-		}
-	}
-	return true
+// The configured start production rules are tried in order, and the first
+// start production rule which matches the input is returned. If none match, the
+// parse errors at the furthest offset reached are returned. The first-set
+// lookup statistics are accumulated over all start production rules tried.
+func parse(grammar ebnf.Grammar, input []byte, conf *config) (string, *eval.ParseNode, []eval.ParseError, eval.FirstSetStats, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if conf.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.timeout)
+		defer cancel()
+	}
+	var furthest []eval.ParseError
+	var total eval.FirstSetStats
+	for _, start := range conf.starts {
+		root, errs, stats, err := eval.Parse(ctx, grammar, input, &conf.Config, start)
+		if err != nil {
+			return "", nil, nil, total, errors.WithStack(err)
+		}
+		total.Total += stats.Total
+		total.Pruned += stats.Pruned
+		if len(errs) == 0 {
+			return start, root, nil, total, nil
+		}
+		if len(furthest) == 0 || errs[0].Offset > furthest[0].Offset {
+			furthest = errs
+		}
+	}
+	return "", nil, furthest, total, nil
 }
 
-// ### [ Helper functions ] ####################################################
-
-// exprString returns the string representation of the given EBNF expression.
-func exprString(x ebnf.Expression) string {
-	switch x := x.(type) {
-	case *ebnf.Production:
-		return fmt.Sprintf("%v = %v .", exprString(x.Name), exprString(x.Expr))
-	case ebnf.Alternative:
-		buf := strings.Builder{}
-		for i, e := range x {
-			if i != 0 {
-				buf.WriteString(" | ")
-			}
-			buf.WriteString(exprString(e))
-		}
-		return buf.String()
-	case ebnf.Sequence:
-		buf := strings.Builder{}
-		for i, e := range x {
-			if i != 0 {
-				buf.WriteString(" ")
-			}
-			buf.WriteString(exprString(e))
-		}
-		return buf.String()
-	case *ebnf.Name:
-		return x.String
-	case *ebnf.Token:
-		return fmt.Sprintf("%q", x.String)
-	case *ebnf.Range:
-		return fmt.Sprintf("%v … %v", exprString(x.Begin), exprString(x.End))
-	case *ebnf.Group:
-		return fmt.Sprintf("( %v )", exprString(x.Body))
-	case *ebnf.Option:
-		return fmt.Sprintf("[ %v ]", exprString(x.Body))
-	case *ebnf.Repetition:
-		return fmt.Sprintf("{ %v }", exprString(x.Body))
-	default:
-		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+// benchmark parses the given input n times and reports the throughput to
+// standard error.
+func benchmark(grammar ebnf.Grammar, input []byte, conf *config, n int) error {
+	begin := time.Now()
+	for i := 0; i < n; i++ {
+		_, _, errs, _, err := parse(grammar, input, conf)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(errs) > 0 {
+			return errors.Errorf("unable to parse input; %v", errs[0])
+		}
 	}
+	elapsed := time.Since(begin)
+	bytesPerSec := float64(n*(len(input)-conf.From)) / elapsed.Seconds()
+	fmt.Fprintf(os.Stderr, "bench: %d runs\t%d ns/op\t%.2f MB/s\n", n, elapsed.Nanoseconds()/int64(n), bytesPerSec/1e6)
+	return nil
 }
 
-// eof signals end of input.
-const eof rune = -1
-
-// nextRune returns the next Unicode rune of the input source.
-func (p *parser) nextRune() rune {
-	if p.pos >= len(p.input) {
-		p.eof = true
-		fmt.Println("eof")
-		return eof
-	}
-	r, size := utf8.DecodeRune(p.input[p.pos:])
-	p.pos += size
-	fmt.Println("pos:", p.pos, len(p.input))
-	return r
-}
+// ### [ Helper functions ] ####################################################
 
 // parseGrammar parses the given EBNF grammar and determines its start
 // production rule.
 func parseGrammar(grammarPath string) (ebnf.Grammar, string, error) {
-	f, err := os.Open(grammarPath)
+	grammar, err := loadGrammar(grammarPath, make(map[string]bool))
 	if err != nil {
 		return nil, "", errors.WithStack(err)
 	}
-	defer f.Close()
-	br := bufio.NewReader(f)
-	grammar, err := ebnf.Parse(grammarPath, br)
-	if err != nil {
-		return nil, "", errors.WithStack(err)
-	}
-	// Find first syntactic production rule by minimum file offset.
+	// Find first syntactic production rule by minimum file offset, preferring
+	// production rules of the root grammar over included ones.
 	var firstProd string
-	min := -1
+	min, minRoot := -1, false
 	for name, prod := range grammar {
 		r, _ := utf8.DecodeRuneInString(name)
 		if unicode.IsUpper(r) {
 			off := prod.Name.Pos().Offset
-			if min == -1 || off < min {
+			root := prod.Name.Pos().Filename == grammarPath
+			if min == -1 || (root && !minRoot) || (root == minRoot && off < min) {
 				firstProd = name
-				min = off
+				min, minRoot = off, root
 			}
 		}
 	}
@@ -419,94 +660,58 @@ func parseGrammar(grammarPath string) (ebnf.Grammar, string, error) {
 	return grammar, firstProd, nil
 }
 
-func (p *parser) firstSet(grammar ebnf.Grammar) map[string]map[rune]bool {
-	m := make(map[string]map[rune]bool)
-	for name, prod := range grammar {
-		m[name] = make(map[rune]bool)
-		p.firstProd(prod, m, name)
-	}
-	return m
-}
-
-func (p *parser) firstProd(x *ebnf.Production, m map[string]map[rune]bool, name string) bool {
-	return p.firstExpr(x.Expr, m, name)
-}
+// reInclude matches include directives of EBNF grammars.
+//
+//	/* include "other.ebnf" */
+var reInclude = regexp.MustCompile(`/\*\s*include\s+("(?:[^"\\]|\\.)*")\s*\*/`)
 
-func (p *parser) firstExpr(x ebnf.Expression, m map[string]map[rune]bool, name string) bool {
-	switch x := x.(type) {
-	case *ebnf.Production:
-		panic(fmt.Errorf("support for expression %T not yet implemented", x))
-	case ebnf.Alternative:
-		return p.firstAlt(x, m, name)
-	case ebnf.Sequence:
-		return p.firstSeq(x, m, name)
-	case *ebnf.Name:
-		return p.firstName(x, m, name)
-	case *ebnf.Token:
-		return p.firstToken(x, m, name)
-	case *ebnf.Range:
-		return p.firstRange(x, m, name)
-	case *ebnf.Group:
-		return p.firstGroup(x, m, name)
-	case *ebnf.Option:
-		return p.firstOpt(x, m, name)
-	case *ebnf.Repetition:
-		return p.firstRep(x, m, name)
-	default:
-		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+// loadGrammar parses the given EBNF grammar and merges the production rules of
+// included grammars, as specified by include directives. Paths of included
+// grammars are relative to the directory of the including grammar.
+//
+// The seen map tracks grammars by absolute path; true while a grammar is being
+// loaded (to detect circular includes), and false once it has been loaded (to
+// load grammars included more than once only once).
+func loadGrammar(grammarPath string, seen map[string]bool) (ebnf.Grammar, error) {
+	absPath, err := filepath.Abs(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-}
-
-// the return value report whether the expression can be empty.
-func (p *parser) firstAlt(x ebnf.Alternative, m map[string]map[rune]bool, name string) bool {
-	empty := false
-	for _, e := range x {
-		if p.firstExpr(e, m, name) {
-			empty = true
+	if loading, ok := seen[absPath]; ok {
+		if loading {
+			return nil, errors.Errorf("circular include of grammar %q", grammarPath)
 		}
+		// already loaded.
+		return ebnf.Grammar{}, nil
 	}
-	return empty
-}
-
-func (p *parser) firstSeq(x ebnf.Sequence, m map[string]map[rune]bool, name string) bool {
-	empty := true
-	for _, e := range x {
-		if !p.firstExpr(e, m, name) {
-			empty = false
-		}
+	seen[absPath] = true
+	buf, err := ioutil.ReadFile(grammarPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	return empty
-}
-
-func (p *parser) firstName(x *ebnf.Name, m map[string]map[rune]bool, name string) bool {
-	return p.firstProd(p.grammar[x.String], m, name)
-}
-
-func (p *parser) firstToken(x *ebnf.Token, m map[string]map[rune]bool, name string) bool {
-	r, _ := utf8.DecodeRuneInString(x.String)
-	m[name][r] = true
-	return false
-}
-
-func (p *parser) firstRange(x *ebnf.Range, m map[string]map[rune]bool, name string) bool {
-	from, _ := utf8.DecodeRuneInString(x.Begin.String)
-	to, _ := utf8.DecodeRuneInString(x.End.String)
-	for r := from; r <= to; r++ {
-		m[name][r] = true
+	grammar, err := ebnf.Parse(grammarPath, bytes.NewReader(buf))
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	return false
-}
-
-func (p *parser) firstGroup(x *ebnf.Group, m map[string]map[rune]bool, name string) bool {
-	return p.firstExpr(x.Body, m, name)
-}
-
-func (p *parser) firstOpt(x *ebnf.Option, m map[string]map[rune]bool, name string) bool {
-	p.firstExpr(x.Body, m, name)
-	return true
-}
-
-func (p *parser) firstRep(x *ebnf.Repetition, m map[string]map[rune]bool, name string) bool {
-	p.firstExpr(x.Body, m, name)
-	return true
+	for _, m := range reInclude.FindAllSubmatch(buf, -1) {
+		includePath, err := strconv.Unquote(string(m[1]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid include directive %q in grammar %q", m[0], grammarPath)
+		}
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(grammarPath), includePath)
+		}
+		dbg.Println("include:", includePath)
+		included, err := loadGrammar(includePath, seen)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		merged, errs := analysis.Merge(grammar, included, analysis.ErrorOnConflict)
+		if len(errs) > 0 {
+			return nil, errors.WithStack(errs[0])
+		}
+		grammar = merged
+	}
+	seen[absPath] = false
+	return grammar, nil
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/eval"
+)
+
+func BenchmarkParse(b *testing.B) {
+	const src = `
+List = Item { "," Item } .
+Item = ident | number | "[" List "]" .
+ident = letter { letter | digit } .
+number = digit { digit } .
+letter = "a" … "z" | "_" .
+digit = "0" … "9" .
+skip = " " .
+`
+	input := []byte(strings.Repeat("foo, 123, [bar_2, 45, [x]], ", 50) + "end")
+	grammarPath := filepath.Join(b.TempDir(), "list.ebnf")
+	if err := ioutil.WriteFile(grammarPath, []byte(src), 0644); err != nil {
+		b.Fatalf("unable to write grammar; %v", err)
+	}
+	grammar, _, err := parseGrammar(grammarPath)
+	if err != nil {
+		b.Fatalf("%+v", err)
+	}
+	golden := []struct {
+		name string
+		// Production rules to inline.
+		inline []string
+	}{
+		{name: "default"},
+		{name: "inline", inline: []string{"letter", "digit"}},
+	}
+	for _, g := range golden {
+		b.Run(g.name, func(b *testing.B) {
+			grammar := grammar
+			if len(g.inline) > 0 {
+				var err error
+				if grammar, err = inlineGrammar(grammar, g.inline); err != nil {
+					b.Fatalf("%+v", err)
+				}
+			}
+			conf := &config{
+				Config: eval.Config{
+					SkipRule: "skip",
+					Tree:     true,
+					First:    analysis.First(grammar),
+					Nullable: analysis.Nullable(grammar),
+				},
+				starts: []string{"List"},
+			}
+			b.SetBytes(int64(len(input)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, root, errs, _, err := parse(grammar, input, conf)
+				if err != nil {
+					b.Fatalf("%+v", err)
+				}
+				if len(errs) > 0 || root.End != len(input) {
+					b.Fatalf("unable to parse input; %v", errs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// SourceMap maps offsets of generated output (e.g. printed parse trees) to
+// offsets of the input source, and back.
+type SourceMap struct {
+	// Mappings in order of generated offset.
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Mapping maps an offset of the generated output to an offset of the input
+// source.
+type Mapping struct {
+	// Offset in the input source.
+	Src int `json:"src"`
+	// Offset in the generated output.
+	Gen int `json:"gen"`
+}
+
+// AddMapping adds a mapping from the given offset of the generated output to
+// the given offset of the input source. Mappings must be added in order of
+// generated offset.
+func (sm *SourceMap) AddMapping(srcOffset, genOffset int) {
+	sm.Mappings = append(sm.Mappings, Mapping{Src: srcOffset, Gen: genOffset})
+}
+
+// LookupGen returns the offset of the input source corresponding to the given
+// offset of the generated output; i.e. of the closest mapping at or before the
+// generated offset, or -1 if none.
+func (sm *SourceMap) LookupGen(genOffset int) int {
+	i := sort.Search(len(sm.Mappings), func(i int) bool {
+		return sm.Mappings[i].Gen > genOffset
+	})
+	if i == 0 {
+		return -1
+	}
+	return sm.Mappings[i-1].Src
+}
+
+// LookupSrc returns the offset of the generated output corresponding to the
+// given offset of the input source; i.e. of the first mapping of the closest
+// input offset at or before the given offset, or -1 if none.
+func (sm *SourceMap) LookupSrc(srcOffset int) int {
+	gen, src := -1, -1
+	for _, m := range sm.Mappings {
+		if m.Src <= srcOffset && m.Src > src {
+			gen, src = m.Gen, m.Src
+		}
+	}
+	return gen
+}
+
+// writeSourceMap writes the source map of the given input file in JSON format
+// to w, followed by a newline.
+func writeSourceMap(w io.Writer, inputPath string, sm *SourceMap) error {
+	v := struct {
+		Path string `json:"path"`
+		*SourceMap
+	}{
+		Path:      inputPath,
+		SourceMap: sm,
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
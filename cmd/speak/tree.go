@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/eval"
+	"github.com/pkg/errors"
+)
+
+// printTree prints the parse tree of the given input file to standard output,
+// in the given output format (tree, json, sexpr or none). The start and end
+// offset of each parse tree node in the printed output are mapped to the input
+// source by the source map of the tree and sexpr output formats, if non-nil.
+func printTree(outputFormat, inputPath string, input []byte, root *eval.ParseNode, sm *SourceMap) error {
+	switch outputFormat {
+	case "tree":
+		fmt.Print(indentTree(input, root, 0, sm, 0))
+	case "json":
+		v := struct {
+			Path string          `json:"path"`
+			Tree *eval.ParseNode `json:"tree"`
+		}{
+			Path: inputPath,
+			Tree: root,
+		}
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Println(string(buf))
+	case "sexpr":
+		fmt.Println(sexpr(input, root, sm, 0))
+	case "none":
+		// nothing to do.
+	default:
+		panic(fmt.Errorf("support for output format %q not yet implemented", outputFormat))
+	}
+	return nil
+}
+
+// indentTree returns the indented tree representation of the given parse tree
+// node, with one node per line. The representation is mapped to the input
+// source by the source map (if non-nil), starting at the given offset of the
+// generated output.
+//
+//	Expr
+//	  Term
+//	    number "42"
+func indentTree(input []byte, node *eval.ParseNode, depth int, sm *SourceMap, gen int) string {
+	buf := &strings.Builder{}
+	buf.WriteString(strings.Repeat("  ", depth))
+	if sm != nil {
+		sm.AddMapping(node.Start, gen+buf.Len())
+	}
+	buf.WriteString(nodeLabel(input, node))
+	buf.WriteString("\n")
+	for _, child := range node.Children {
+		buf.WriteString(indentTree(input, child, depth+1, sm, gen+buf.Len()))
+	}
+	if sm != nil {
+		sm.AddMapping(node.End, gen+buf.Len())
+	}
+	return buf.String()
+}
+
+// sexpr returns the S-expression representation of the given parse tree node.
+// The representation is mapped to the input source by the source map (if
+// non-nil), starting at the given offset of the generated output.
+//
+//	(Expr (Term (number "42")))
+func sexpr(input []byte, node *eval.ParseNode, sm *SourceMap, gen int) string {
+	buf := &strings.Builder{}
+	if sm != nil {
+		sm.AddMapping(node.Start, gen)
+	}
+	buf.WriteString("(")
+	buf.WriteString(nodeLabel(input, node))
+	for _, child := range node.Children {
+		buf.WriteString(" ")
+		buf.WriteString(sexpr(input, child, sm, gen+buf.Len()))
+	}
+	buf.WriteString(")")
+	if sm != nil {
+		sm.AddMapping(node.End, gen+buf.Len())
+	}
+	return buf.String()
+}
+
+// echoTree returns the matched source text of the production rules of the
+// given parse tree, with one line per production rule in order of evaluation
+// (i.e. child nodes before their parent). As the parse tree only holds
+// production rules of successful parses, production rules which have been
+// backtracked are omitted.
+//
+//	number: "42"
+//	Term: "42"
+func echoTree(node *eval.ParseNode) string {
+	buf := &strings.Builder{}
+	for _, child := range node.Children {
+		buf.WriteString(echoTree(child))
+	}
+	fmt.Fprintf(buf, "%s: %q\n", node.Name, node.TextString())
+	return buf.String()
+}
+
+// nodeLabel returns the label of the given parse tree node; the production
+// name, followed by the quoted source text for lexical production rules.
+func nodeLabel(input []byte, node *eval.ParseNode) string {
+	if analysis.IsLexical(node.Name) {
+		return fmt.Sprintf("%s %q", node.Name, input[node.Start:node.End])
+	}
+	return node.Name
+}
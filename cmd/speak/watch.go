@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Polling interval of modifications in watch mode.
+const watchInterval = 500 * time.Millisecond
+
+// watch re-runs speak with the given command line arguments each time one of
+// the given files (grammar and input files) is modified, until interrupted. The
+// speak command is run as a separate process, which reloads the grammar before
+// parsing. Files are polled for modifications using os.Stat.
+func watch(runArgs, paths []string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	var prev map[string]time.Time
+	for {
+		cur := modTimes(paths)
+		if prev == nil || changed(prev, cur) {
+			if prev != nil {
+				fmt.Fprintln(os.Stderr, strings.Repeat("-", 80))
+			}
+			prev = cur
+			cmd := exec.Command(self, runArgs...)
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err := cmd.Run(); err != nil {
+				if _, ok := err.(*exec.ExitError); !ok {
+					return errors.WithStack(err)
+				}
+				// parse failures are reported by the run.
+			}
+		}
+		select {
+		case <-sigs:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchArgs returns the command line arguments of runs in watch mode; i.e. the
+// flags set on the command line, except for the watch flag, followed by the
+// positional arguments. Flags are rebuilt from their parsed values, so that
+// positional arguments and flag values named "watch" are retained.
+func watchArgs(fs *flag.FlagSet) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "watch" {
+			return
+		}
+		args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+	})
+	// terminate flags, so that positional arguments are never parsed as flags.
+	args = append(args, "--")
+	return append(args, fs.Args()...)
+}
+
+// modTimes returns the modification times of the given files. The zero time is
+// used for files which cannot be accessed (e.g. while being rewritten).
+func modTimes(paths []string) map[string]time.Time {
+	m := make(map[string]time.Time)
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil {
+			m[path] = fi.ModTime()
+		} else {
+			m[path] = time.Time{}
+		}
+	}
+	return m
+}
+
+// changed reports whether any modification time differs between prev and cur.
+func changed(prev, cur map[string]time.Time) bool {
+	for path, t := range cur {
+		if !prev[path].Equal(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestWatchArgs(t *testing.T) {
+	golden := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"-watch", "a.txt"}, want: "-- a.txt"},
+		{args: []string{"-watch=true", "-tree", "a.txt"}, want: "-tree=true -- a.txt"},
+		{args: []string{"-watch=1", "a.txt"}, want: "-- a.txt"},
+		{args: []string{"-watch=t", "a.txt"}, want: "-- a.txt"},
+		// flag value named watch.
+		{args: []string{"-skip-rule", "watch", "-watch", "a.txt"}, want: "-skip-rule=watch -- a.txt"},
+		// positional argument named watch.
+		{args: []string{"--watch", "watch"}, want: "-- watch"},
+		// positional argument starting with a dash.
+		{args: []string{"-watch", "--", "-a.txt"}, want: "-- -a.txt"},
+	}
+	for _, g := range golden {
+		fs := flag.NewFlagSet("speak", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		fs.Bool("watch", false, "")
+		fs.Bool("tree", false, "")
+		fs.String("skip-rule", "skip", "")
+		if err := fs.Parse(g.args); err != nil {
+			t.Fatalf("%q: unable to parse arguments; %v", g.args, err)
+		}
+		if got := strings.Join(watchArgs(fs), " "); got != g.want {
+			t.Errorf("%q: arguments mismatch; expected %q, got %q", g.args, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// The tokendiff tool compares two token streams in unified diff format, to
+// catch unintended lexer changes during grammar refactoring.
+//
+// Token streams are read from text files with one token per line, holding the
+// token type followed by the token text; e.g. as output for lexical production
+// rules by speak -echo.
+//
+//	ident: "foo"
+//	number: "42"
+//
+// The exit status is 1 if the token streams differ, and 0 otherwise.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Usage: tokendiff [OPTION]... OLD NEW
+
+Flags:`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line arguments.
+	var (
+		// Ignore tokens of the skip production rule.
+		ignoreWhitespace bool
+		// Skip production rule.
+		skipRule string
+		// Number of context lines.
+		context int
+	)
+	flag.BoolVar(&ignoreWhitespace, "ignore-whitespace", false, "ignore tokens of the skip production rule")
+	flag.StringVar(&skipRule, "skip-rule", "skip", "skip production rule (whitespace and comments)")
+	flag.IntVar(&context, "context", 3, "number of context lines")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	oldPath, newPath := flag.Arg(0), flag.Arg(1)
+
+	// Compare token streams.
+	ignore := ""
+	if ignoreWhitespace {
+		ignore = skipRule
+	}
+	a, err := readTokens(oldPath, ignore)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	b, err := readTokens(newPath, ignore)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	hunks := unifiedDiff(a, b, context)
+	if len(hunks) == 0 {
+		return
+	}
+	bw := bufio.NewWriter(os.Stdout)
+	fmt.Fprintf(bw, "--- %s\n", oldPath)
+	fmt.Fprintf(bw, "+++ %s\n", newPath)
+	for _, hunk := range hunks {
+		bw.WriteString(hunk)
+	}
+	if err := bw.Flush(); err != nil {
+		log.Fatalf("%+v", errors.WithStack(err))
+	}
+	os.Exit(1)
+}
+
+// readTokens returns the tokens of the given token stream file, one per line,
+// omitting empty lines and tokens of the given type to ignore (if non-empty).
+func readTokens(path, ignore string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	var tokens []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if len(ignore) > 0 && tokenType(line) == ignore {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "unable to read token stream %q", path)
+	}
+	return tokens, nil
+}
+
+// tokenType returns the token type of the given token line; i.e. the first
+// field, with an optional trailing colon removed.
+func tokenType(line string) string {
+	typ := line
+	if i := strings.IndexAny(line, " \t"); i != -1 {
+		typ = line[:i]
+	}
+	return strings.TrimSuffix(typ, ":")
+}
+
+// op is an edit operation of a line.
+type op struct {
+	// Edit kind; ' ' for equal, '-' for deleted and '+' for inserted lines.
+	kind byte
+	// Line text.
+	line string
+	// Index of the line in a and b before the edit operation.
+	a, b int
+}
+
+// unifiedDiff returns the hunks of the unified diff between the lines a and b,
+// with the given number of context lines.
+func unifiedDiff(a, b []string, context int) []string {
+	ops := editScript(a, b)
+	var hunks []string
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk while changes are within twice the context of each
+		// other.
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind == ' ' {
+				continue
+			}
+			if j-end-1 > 2*context {
+				break
+			}
+			end = j
+		}
+		end = min(end+context+1, len(ops))
+		hunks = append(hunks, hunk(ops[start:end]))
+		i = end
+	}
+	return hunks
+}
+
+// hunk returns the unified diff hunk of the given edit operations.
+func hunk(ops []op) string {
+	na, nb := 0, 0
+	for _, o := range ops {
+		if o.kind != '+' {
+			na++
+		}
+		if o.kind != '-' {
+			nb++
+		}
+	}
+	// Line numbers are 1-based, except for empty ranges, which refer to the
+	// line before the range.
+	la, lb := ops[0].a, ops[0].b
+	if na > 0 {
+		la++
+	}
+	if nb > 0 {
+		lb++
+	}
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", la, na, lb, nb)
+	for _, o := range ops {
+		fmt.Fprintf(buf, "%c%s\n", o.kind, o.line)
+	}
+	return buf.String()
+}
+
+// editScript returns the shortest edit script transforming the lines a into b,
+// using the O(ND) difference algorithm of Myers.
+func editScript(a, b []string) []op {
+	n, m := len(a), len(b)
+	off := n + m + 1
+	v := make([]int, 2*off+1)
+	var trace [][]int
+	d := 0
+loop:
+	for ; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				// insertion.
+				x = v[off+k+1]
+			} else {
+				// deletion.
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break loop
+			}
+		}
+	}
+	// Backtrack from the end to recover the edit operations, in reverse order.
+	var rev []op
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, op{kind: ' ', line: a[x], a: x, b: y})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, op{kind: '+', line: b[y], a: x, b: y})
+		} else {
+			x--
+			rev = append(rev, op{kind: '-', line: a[x], a: x, b: y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, op{kind: ' ', line: a[x], a: x, b: y})
+	}
+	ops := make([]op, len(rev))
+	for i, o := range rev {
+		ops[len(rev)-1-i] = o
+	}
+	return ops
+}
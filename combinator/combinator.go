@@ -0,0 +1,119 @@
+// Package combinator implements parser combinators mirroring the expressions of
+// EBNF grammars.
+//
+// Parsers are composed in Go code, rather than read from EBNF files; e.g. the
+// parser of
+//
+//	number = digit { digit } .
+//	digit  = "0" … "9" .
+//
+// is expressed as
+//
+//	digit := combinator.Range('0', '9')
+//	number := combinator.Seq(digit, combinator.Rep(digit))
+//
+// Recursive parsers refer to themselves through a closure.
+package combinator
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// A Parser parses input starting at position pos. If successful, it returns the
+// position after the parsed input and true; otherwise, it returns pos and
+// false.
+type Parser func(input []byte, pos int) (newPos int, ok bool)
+
+// Seq returns a parser of a sequence of expressions. All must be valid.
+//
+//	x y z
+func Seq(parsers ...Parser) Parser {
+	return func(input []byte, pos int) (int, bool) {
+		cur := pos
+		for _, p := range parsers {
+			next, ok := p(input, cur)
+			if !ok {
+				return pos, false
+			}
+			cur = next
+		}
+		return cur, true
+	}
+}
+
+// Alt returns a parser of a list of alternative expressions. One must be
+// valid; the first valid alternative is used.
+//
+//	x | y | z
+func Alt(parsers ...Parser) Parser {
+	return func(input []byte, pos int) (int, bool) {
+		for _, p := range parsers {
+			if next, ok := p(input, pos); ok {
+				return next, true
+			}
+		}
+		return pos, false
+	}
+}
+
+// Rep returns a parser of a repeated expression. Must have zero or more valid
+// expressions.
+//
+//	{ body }
+func Rep(p Parser) Parser {
+	return func(input []byte, pos int) (int, bool) {
+		for {
+			next, ok := p(input, pos)
+			if !ok || next == pos {
+				// stop on invalid body, or body matching empty input to prevent
+				// infinite loop.
+				return pos, true
+			}
+			pos = next
+		}
+	}
+}
+
+// Opt returns a parser of an optional expression. Must have zero or one valid
+// expression.
+//
+//	[ body ]
+func Opt(p Parser) Parser {
+	return func(input []byte, pos int) (int, bool) {
+		if next, ok := p(input, pos); ok {
+			return next, true
+		}
+		return pos, true
+	}
+}
+
+// Tok returns a parser of a token. Must match the token exactly.
+//
+//	"foo"
+func Tok(s string) Parser {
+	tok := []byte(s)
+	return func(input []byte, pos int) (int, bool) {
+		if pos > len(input) || !bytes.HasPrefix(input[pos:], tok) {
+			return pos, false
+		}
+		return pos + len(tok), true
+	}
+}
+
+// Range returns a parser of a character range. Must be within the range
+// (inclusive).
+//
+//	"a" … "z"
+func Range(from, to rune) Parser {
+	return func(input []byte, pos int) (int, bool) {
+		if pos >= len(input) {
+			return pos, false
+		}
+		r, size := utf8.DecodeRune(input[pos:])
+		if r < from || r > to {
+			return pos, false
+		}
+		return pos + size, true
+	}
+}
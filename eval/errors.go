@@ -0,0 +1,164 @@
+package eval
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"golang.org/x/exp/ebnf"
+)
+
+// ParseError is a parse error at a given position of the input source.
+type ParseError struct {
+	// Byte offset of the error in the input source.
+	Offset int `json:"offset"`
+	// Line number of the error (1-based).
+	Line int `json:"line"`
+	// Column number of the error in runes (1-based).
+	Col int `json:"col"`
+	// Expected input.
+	Expected string `json:"expected"`
+	// Actual input.
+	Got string `json:"got"`
+	// Name of the production rule being evaluated when the error occurred.
+	Context string `json:"context,omitempty"`
+	// Name of the outermost production rule being evaluated which started at
+	// the offset of the error; or empty if none.
+	outer string
+}
+
+// Error returns an error message of the parse error.
+func (e ParseError) Error() string {
+	msg := fmt.Sprintf("%d:%d: expected %s, got %s", e.Line, e.Col, e.Expected, e.Got)
+	if len(e.Context) > 0 {
+		msg += fmt.Sprintf(" (in production %s)", e.Context)
+	}
+	return msg
+}
+
+// DepthLimitError is returned when parsing is aborted as the maximum
+// production rule nesting depth has been reached.
+type DepthLimitError struct {
+	// Maximum nesting depth.
+	Depth int
+	// Name of the production rule exceeding the maximum nesting depth.
+	Production string
+}
+
+// Error returns an error message of the depth limit error.
+func (e *DepthLimitError) Error() string {
+	return fmt.Sprintf("maximum production rule nesting depth %d reached in production %s", e.Depth, e.Production)
+}
+
+// fail records a parse error at the given offset of the input source. Only the
+// parse errors at the furthest offset reached are kept, as earlier errors are
+// the result of backtracking.
+func (p *parser) fail(offset int, expected, got string) {
+	if p.skipping || offset < p.errOffset {
+		return
+	}
+	if offset > p.errOffset {
+		p.errOffset = offset
+		p.errs = p.errs[:0]
+	}
+	e := ParseError{
+		Offset:   offset,
+		Expected: expected,
+		Got:      got,
+	}
+	if n := len(p.stack); n > 0 {
+		e.Context = p.stack[n-1].name
+	}
+	for _, f := range p.stack {
+		if f.start == offset {
+			e.outer = f.name
+			break
+		}
+	}
+	p.errs = append(p.errs, e)
+}
+
+// collectErrors returns the parse errors at the furthest offset reached in the
+// input source, with line and column information.
+func (p *parser) collectErrors() []ParseError {
+	var errs []ParseError
+	seen := make(map[ParseError]bool)
+	for _, e := range p.errs {
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		e.Line, e.Col = p.lineCol(e.Offset)
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// recoverSeq attempts to recover from the failed evaluation of the given
+// element of a sequence, by skipping input up to the next terminal in the
+// FOLLOW set of the syntactic production rule being evaluated. The recovery is
+// recorded as a parse error, and the remaining elements of the sequence are
+// skipped.
+func (p *parser) recoverSeq(x ebnf.Expression) bool {
+	if p.follow == nil || p.skipping || len(p.stack) == 0 {
+		return false
+	}
+	name := p.stack[len(p.stack)-1].name
+	if analysis.IsLexical(name) {
+		return false
+	}
+	p.skip()
+	follow := p.follow[name]
+	start := p.pos
+	for pos := start; ; {
+		if follow.Match(p.input[pos:]) {
+			e := ParseError{
+				Offset:   start,
+				Expected: exprString(x),
+				Got:      p.quoteInput(start, 1),
+				Context:  name,
+			}
+			if pos > start {
+				e.Got = fmt.Sprintf("%q", p.input[start:pos])
+			}
+			e.Line, e.Col = p.lineCol(start)
+			p.recovered = append(p.recovered, e)
+			p.pos = pos
+			return true
+		}
+		if pos >= len(p.input) {
+			return false
+		}
+		_, size := utf8.DecodeRune(p.input[pos:])
+		pos += size
+	}
+}
+
+// lineCol returns the line and column number (1-based) of the given offset in
+// the input source.
+func (p *parser) lineCol(offset int) (line, col int) {
+	line, col = 1, 1
+	for _, r := range string(p.input[:offset]) {
+		if r == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+	return line, col
+}
+
+// quoteInput returns a quoted string of the input source of the given length
+// in runes, starting at offset, or "EOF" if offset is at end of input.
+func (p *parser) quoteInput(offset, n int) string {
+	if offset >= len(p.input) {
+		return "EOF"
+	}
+	end := offset
+	for i := 0; i < n && end < len(p.input); i++ {
+		_, size := utf8.DecodeRune(p.input[end:])
+		end += size
+	}
+	return fmt.Sprintf("%q", p.input[offset:end])
+}
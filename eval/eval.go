@@ -0,0 +1,737 @@
+// Package eval implements runtime evaluation of EBNF grammars, as used by the
+// speak tool to parse input.
+package eval
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+var (
+	// dbg is a logger with the "eval:" prefix which logs debug messages to
+	// standard error.
+	dbg = log.New(ioutil.Discard, term.MagentaBold("eval:")+" ", 0)
+	// warn is a logger with the "eval:" prefix which logs warning messages to
+	// standard error.
+	warn = log.New(ioutil.Discard, term.RedBold("eval:")+" ", 0)
+)
+
+// Config holds the configuration of the runtime parser.
+type Config struct {
+	// Skip production rule.
+	SkipRule string
+	// Maximum production rule nesting depth; 0 disables limit.
+	MaxDepth int
+	// Writer of parse trace events; nil disables tracing.
+	Trace *bufio.Writer
+	// Build parse trees.
+	Tree bool
+	// Start byte offset of the input range to parse.
+	From int
+	// Succeed if the grammar matches a prefix of the input.
+	Partial bool
+	// Recover from parse errors in sequences.
+	Recovery bool
+	// FIRST sets of production rules, used for first-set guided alternative
+	// selection.
+	First map[string]analysis.Set
+	// Nullable production rules.
+	Nullable map[string]bool
+	// First runes of production rules; nil if not used.
+	FirstRunes map[string]map[rune]bool
+	// Writer of streaming parse events; nil disables streaming.
+	Stream *EventStream
+	// Writer of output maps of terminal matches; nil disables output maps.
+	OutputMap *csv.Writer
+	// Cache of production rule results, reused across parses of edited input;
+	// nil disables memoization. Memoization requires parse trees, and is
+	// disabled when tracing, streaming, output maps or error recovery is
+	// enabled.
+	Memo *Memo
+}
+
+// Parse parses the given input from the configured start offset by runtime
+// evaluation of the grammar from the given start production rule, using the
+// skip production rule to ignore whitespace and comments. The parse tree is
+// returned if the input is valid, consisting of only the root node unless parse
+// trees are enabled, and the parse errors if the input is invalid. In partial
+// mode, the input is valid if the grammar matches a prefix of the input. Parsing
+// is aborted with an error when the context is cancelled. The first-set lookup
+// statistics of the parse are returned alongside the parse result.
+//
+// If a memo is configured, production rule results are reused from and stored
+// in the memo. The parse errors of invalid input are reported by evaluating the
+// grammar again without the memo, as reused results record no parse errors.
+func Parse(ctx context.Context, grammar ebnf.Grammar, input []byte, conf *Config, start string) (root *ParseNode, errs []ParseError, stats FirstSetStats, err error) {
+	if conf.Memo != nil && conf.Tree && conf.Trace == nil && conf.Stream == nil && conf.OutputMap == nil && !conf.Recovery {
+		root, _, stats, err = parse(ctx, grammar, input, conf, start, conf.Memo)
+		if err != nil || root != nil {
+			return root, nil, stats, err
+		}
+	}
+	return parse(ctx, grammar, input, conf, start, nil)
+}
+
+// parse parses the given input by runtime evaluation of the grammar from the
+// given start production rule, using the given memo of production rule results
+// (nil disables memoization).
+func parse(ctx context.Context, grammar ebnf.Grammar, input []byte, conf *Config, start string, memo *Memo) (root *ParseNode, errs []ParseError, stats FirstSetStats, err error) {
+	p := &parser{
+		ctx:        ctx,
+		grammar:    grammar,
+		skipRule:   conf.SkipRule,
+		input:      input,
+		pos:        conf.From,
+		maxDepth:   conf.MaxDepth,
+		trace:      conf.Trace,
+		tree:       conf.Tree,
+		first:      conf.First,
+		nullable:   conf.Nullable,
+		firstRunes: conf.FirstRunes,
+		altFirst:   make(map[*ebnf.Expression]analysis.Set),
+		stream:     conf.Stream,
+		outputMap:  conf.OutputMap,
+		memo:       memo,
+	}
+	if conf.Recovery {
+		p.follow = analysis.Follow(grammar, start)
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			a, ok := e.(abort)
+			if !ok {
+				panic(e)
+			}
+			stats, err = p.stats, a.err
+		}
+	}()
+	events := p.saveEvents()
+	ret := p.evalProd(p.grammar[start])
+	p.skip()
+	dbg.Println("Parse:")
+	dbg.Printf("   Parse.ret: %v", ret)
+	dbg.Printf("   Parse.len: %v %v", len(input), p.pos)
+	if ret && (p.pos == len(input) || conf.Partial) {
+		if len(p.recovered) > 0 {
+			// retract streaming parse events of failed parse.
+			p.retract(events)
+			return nil, p.recovered, p.stats, nil
+		}
+		if p.outputMap != nil {
+			if err := p.writeOutputMap(); err != nil {
+				return nil, nil, p.stats, errors.WithStack(err)
+			}
+		}
+		if len(p.nodes) > 0 {
+			root = p.nodes[0]
+		}
+		return root, nil, p.stats, nil
+	}
+	// retract streaming parse events of failed parse.
+	p.retract(events)
+	if p.pos < len(input) {
+		p.fail(p.pos, "EOF", p.quoteInput(p.pos, 1))
+	}
+	return nil, append(p.recovered, p.collectErrors()...), p.stats, nil
+}
+
+// parser holds the state of the EBNF grammar used for parsing.
+type parser struct {
+	// Context used to abort parsing.
+	ctx context.Context
+	// EBNF language grammar.
+	grammar ebnf.Grammar
+	// Name of skip production rule.
+	skipRule string
+	// Input source.
+	input []byte
+	// Current position in input source.
+	pos int
+	// Currently skipping whitespace and comments in evalExpr.
+	skipping bool
+	// Production rules currently being evaluated, innermost last.
+	stack []frame
+	// Maximum production rule nesting depth; 0 disables limit.
+	maxDepth int
+	// Parse errors at the furthest offset reached in the input source.
+	errs []ParseError
+	// Furthest offset of parse errors.
+	errOffset int
+	// Writer of parse trace events; nil disables tracing.
+	trace *bufio.Writer
+	// Build parse tree.
+	tree bool
+	// Parse tree nodes of the production rule currently being evaluated.
+	nodes []*ParseNode
+	// Start and end offset of the most recently skipped whitespace and
+	// comments.
+	skipped [2]int
+	// FOLLOW sets of production rules, used for error recovery; nil disables
+	// error recovery.
+	follow map[string]analysis.Set
+	// Parse errors recovered from, in order of occurrence.
+	recovered []ParseError
+	// FIRST sets of production rules.
+	first map[string]analysis.Set
+	// Nullable production rules.
+	nullable map[string]bool
+	// First runes of production rules, used to reject input which cannot begin
+	// non-nullable production rules; nil if not used.
+	firstRunes map[string]map[rune]bool
+	// FIRST sets of alternatives, indexed by the address of the alternative
+	// expression; nil for nullable alternatives, which are never pruned.
+	altFirst map[*ebnf.Expression]analysis.Set
+	// First-set lookup statistics.
+	stats FirstSetStats
+	// Writer of streaming parse events; nil disables streaming.
+	stream *EventStream
+	// Writer of output maps of terminal matches; nil disables output maps.
+	outputMap *csv.Writer
+	// Terminal matches of the input source, in order of occurrence.
+	matches []termMatch
+	// Cache of production rule results; nil disables memoization.
+	memo *Memo
+	// End offset (exclusive) of the input examined by the production rule
+	// currently being memoized.
+	examined int
+}
+
+// FirstSetStats holds the statistics of first-set guided alternative
+// selection.
+type FirstSetStats struct {
+	// Number of alternatives considered.
+	Total int
+	// Number of alternatives pruned by first-set lookups, without being
+	// evaluated.
+	Pruned int
+}
+
+// Percent returns the percentage of alternatives pruned by first-set lookups.
+func (s FirstSetStats) Percent() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return 100 * float64(s.Pruned) / float64(s.Total)
+}
+
+// frame is a production rule being evaluated.
+type frame struct {
+	// Production name.
+	name string
+	// Start offset of the production rule in the input source.
+	start int
+}
+
+// abort is used to unwind the parser through panic when parsing is aborted.
+type abort struct {
+	err error
+}
+
+// checkAbort aborts parsing if the context of the parser has been cancelled.
+// The cause of the abort error is the error of the context (context.Canceled or
+// context.DeadlineExceeded).
+func (p *parser) checkAbort() {
+	if err := p.ctx.Err(); err != nil {
+		panic(abort{err: errors.Wrapf(err, "parsing aborted at offset %d", p.pos)})
+	}
+}
+
+// skip evaluates the skip production rule to ignore whitespace and comments.
+func (p *parser) skip() {
+	if p.skipping {
+		return
+	}
+	p.skipping = true
+	start := p.pos
+	if skip, ok := p.grammar[p.skipRule]; ok {
+		dbg.Println("skip:", exprString(skip))
+		// record pos, and reset if no whitespace found.
+		for {
+			bak := p.pos
+			if !p.evalExpr(skip.Expr) {
+				// reset pos.
+				p.pos = bak
+				break
+			}
+		}
+	}
+	if p.pos > start {
+		p.skipped = [2]int{start, p.pos}
+	}
+	p.skipping = false
+}
+
+// evalProd evaluates a production rule, reusing the cached result of the
+// production rule at the current offset if memoized.
+func (p *parser) evalProd(x *ebnf.Production) bool {
+	if !p.memoize() {
+		return p.evalRule(x)
+	}
+	key := memoKey{name: x.Name.String, offset: p.pos}
+	if r, ok := p.memo.results[key]; ok {
+		return p.reuse(r)
+	}
+	// record examined input of the production rule, and of the enclosing
+	// production rule.
+	outer, nodes, skipped := p.examined, len(p.nodes), p.skipped
+	p.examined = p.pos
+	ret := p.evalRule(x)
+	r := &memoResult{ok: ret, end: p.pos, examined: p.examined}
+	if len(p.nodes) > nodes {
+		r.node = p.nodes[len(p.nodes)-1]
+	}
+	if p.skipped != skipped {
+		r.skipped = &[2]int{p.skipped[0], p.skipped[1]}
+	}
+	p.memo.results[key] = r
+	if outer > p.examined {
+		p.examined = outer
+	}
+	return ret
+}
+
+// evalRule evaluates the expression of a production rule. Must be valid.
+func (p *parser) evalRule(x *ebnf.Production) bool {
+	dbg.Println("evalRule:", exprString(x))
+	if p.maxDepth > 0 && len(p.stack) >= p.maxDepth {
+		panic(abort{err: errors.WithStack(&DepthLimitError{Depth: p.maxDepth, Production: x.Name.String})})
+	}
+	// skip whitespace and comments before recording the start offset.
+	p.skip()
+	if p.pruneProd(x) {
+		return false
+	}
+	p.traceEvent("enter", x.Name.String, nil)
+	// Parse tree nodes are not recorded for skip production rules, nor within
+	// lexical production rules. The root node is always recorded, to report the
+	// range of input matched by the start production rule.
+	record := !p.skipping && (len(p.stack) == 0 || (p.tree && !analysis.IsLexical(p.stack[len(p.stack)-1].name)))
+	// Streaming parse events are emitted for the production rules of the parse
+	// tree. The events of failed production rules are retracted.
+	stream := p.stream != nil && !p.skipping && (len(p.stack) == 0 || !analysis.IsLexical(p.stack[len(p.stack)-1].name))
+	events := p.saveEvents()
+	parent := p.nodes
+	p.nodes = nil
+	start := p.pos
+	if stream {
+		p.emit("open", x.Name.String, start)
+	}
+	p.stack = append(p.stack, frame{name: x.Name.String, start: start})
+	ret := p.evalExpr(x.Expr)
+	p.stack = p.stack[:len(p.stack)-1]
+	children := p.nodes
+	p.nodes = parent
+	// exclude trailing whitespace and comments from the parse tree node.
+	end := p.pos
+	if end == p.skipped[1] && p.skipped[0] >= start {
+		end = p.skipped[0]
+	}
+	switch {
+	case stream && ret:
+		p.emit("close", x.Name.String, end)
+	case stream:
+		p.retract(events)
+	}
+	if record && ret {
+		node := &ParseNode{
+			Name:     x.Name.String,
+			Start:    start,
+			End:      end,
+			Children: children,
+			Text:     p.input[start:end],
+		}
+		p.nodes = append(p.nodes, node)
+	}
+	p.traceEvent("exit", x.Name.String, &ret)
+	dbg.Printf("   evalRule.ret: %v", ret)
+	return ret
+}
+
+// traceEvent is a parse trace event.
+type traceEvent struct {
+	// Event kind (enter or exit).
+	Event string `json:"event"`
+	// Production name.
+	Prod string `json:"prod"`
+	// Position in input source.
+	Pos int `json:"pos"`
+	// Result of production rule evaluation; only present on exit.
+	Result *bool `json:"result,omitempty"`
+}
+
+// traceEvent writes a parse trace event of the given production rule, if
+// tracing is enabled.
+func (p *parser) traceEvent(event, prod string, result *bool) {
+	if p.trace == nil {
+		return
+	}
+	e := traceEvent{
+		Event:  event,
+		Prod:   prod,
+		Pos:    p.pos,
+		Result: result,
+	}
+	if err := json.NewEncoder(p.trace).Encode(e); err != nil {
+		panic(abort{err: errors.WithStack(err)})
+	}
+}
+
+func (p *parser) evalExpr(x ebnf.Expression) bool {
+	dbg.Println("evalExpr:", exprString(x))
+	p.checkAbort()
+	// skip whitespace and comments in between expressions.
+	p.skip()
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+		return true
+	case *ebnf.Production:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	case ebnf.Alternative:
+		ret := p.evalAlt(x)
+		dbg.Printf("   evalExpr.evalAlt.ret: %v", ret)
+		return ret
+	case ebnf.Sequence:
+		ret := p.evalSeq(x)
+		dbg.Printf("   evalExpr.evalSeq.ret: %v", ret)
+		return ret
+	case *ebnf.Name:
+		ret := p.evalName(x)
+		dbg.Printf("   evalExpr.evalName.ret: %v", ret)
+		return ret
+	case *ebnf.Token:
+		ret := p.evalToken(x)
+		dbg.Printf("   evalExpr.evalToken.ret: %v", ret)
+		return ret
+	case *ebnf.Range:
+		ret := p.evalRange(x)
+		dbg.Printf("   evalExpr.evalRange.ret: %v", ret)
+		return ret
+	case *ebnf.Group:
+		ret := p.evalGroup(x)
+		dbg.Printf("   evalExpr.evalGroup.ret: %v", ret)
+		return ret
+	case *ebnf.Option:
+		ret := p.evalOpt(x)
+		dbg.Printf("   evalExpr.evalOpt.ret: %v", ret)
+		return ret
+	case *ebnf.Repetition:
+		ret := p.evalRep(x)
+		dbg.Printf("   evalExpr.evalRep.ret: %v", ret)
+		return ret
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// evalAlt evaluates a list of alternative expressions. One must be valid.
+// Alternatives which cannot begin with the next input are pruned without being
+// evaluated, based on their FIRST sets.
+//
+//	x | y | z
+func (p *parser) evalAlt(x ebnf.Alternative) bool {
+	dbg.Println("evalAlt:", exprString(x))
+	// TODO: Figure out how to try handle multiple valid alternatives. Is this
+	// even needed?
+	for i, e := range x {
+		p.stats.Total++
+		if p.prune(&x[i]) {
+			p.stats.Pruned++
+			continue
+		}
+		// record pos, parse tree nodes, terminal matches and streaming parse
+		// events, and reset for invalid alternatives.
+		bak, nodes, matches, events := p.pos, len(p.nodes), len(p.matches), p.saveEvents()
+		ok := p.evalExpr(e)
+		p.restoreEvents(events, ok)
+		if ok {
+			return true
+		}
+		// reset pos, parse tree nodes and terminal matches.
+		p.pos, p.nodes, p.matches = bak, p.nodes[:nodes], p.matches[:matches]
+	}
+	return false
+}
+
+// prune reports whether the given alternative cannot begin with the next input,
+// based on its FIRST set. The terminals of the FIRST set are recorded as
+// expected input of pruned alternatives.
+func (p *parser) prune(alt *ebnf.Expression) bool {
+	if p.first == nil {
+		return false
+	}
+	first, ok := p.altFirst[alt]
+	if !ok {
+		if !analysis.IsNullable(*alt, p.nullable) {
+			first = analysis.FirstExpr(*alt, p.first, p.nullable)
+		}
+		p.altFirst[alt] = first
+	}
+	if p.memo != nil {
+		p.peekSet(first)
+	}
+	if first == nil || first.Match(p.input[p.pos:]) {
+		return false
+	}
+	for _, t := range first.Sorted() {
+		p.fail(p.pos, t.String(), p.quoteInput(p.pos, 1))
+	}
+	return true
+}
+
+// pruneProd reports whether the given production rule cannot begin with the
+// next input, based on its first runes. Nullable production rules are never
+// pruned. The terminals of the FIRST set are recorded as expected input of
+// pruned production rules.
+func (p *parser) pruneProd(x *ebnf.Production) bool {
+	name := x.Name.String
+	first, ok := p.firstRunes[name]
+	if !ok || p.nullable[name] {
+		return false
+	}
+	if !p.atEOF() {
+		p.peekRune()
+		r, _ := utf8.DecodeRune(p.input[p.pos:])
+		if first[r] {
+			return false
+		}
+	}
+	// record expected input in the context of the production rule.
+	p.stack = append(p.stack, frame{name: name, start: p.pos})
+	for _, t := range p.first[name].Sorted() {
+		p.fail(p.pos, t.String(), p.quoteInput(p.pos, 1))
+	}
+	p.stack = p.stack[:len(p.stack)-1]
+	return true
+}
+
+// evalSeq evaluates a list of sequential expressions. All must be valid.
+//
+//	x y z
+func (p *parser) evalSeq(x ebnf.Sequence) bool {
+	dbg.Println("evalSeq:", exprString(x))
+	// record pos, parse tree nodes, terminal matches and streaming parse events,
+	// and reset if the sequence only partially matches.
+	bak, nodes, matches, events := p.pos, len(p.nodes), len(p.matches), p.saveEvents()
+	for i, e := range x {
+		if !p.evalExpr(e) {
+			// recover if the sequence has consumed input.
+			if i > 0 && p.pos > bak && p.recoverSeq(e) {
+				p.restoreEvents(events, true)
+				return true
+			}
+			// reset pos, parse tree nodes and terminal matches.
+			p.pos, p.nodes, p.matches = bak, p.nodes[:nodes], p.matches[:matches]
+			p.restoreEvents(events, false)
+			return false
+		}
+	}
+	p.restoreEvents(events, true)
+	return true
+}
+
+// evalName evaluates a the expression of a production name. Must be valid.
+//
+//	foo
+func (p *parser) evalName(x *ebnf.Name) bool {
+	dbg.Println("evalName:", exprString(x))
+	prod := p.grammar[x.String]
+	return p.evalProd(prod)
+}
+
+// evalToken evaluates a literal. Must be valid.
+//
+//	"foo"
+func (p *parser) evalToken(x *ebnf.Token) bool {
+	dbg.Println("evalToken:", exprString(x))
+	// record pos, and reset on partial match.
+	bak := p.pos
+	for _, q := range x.String {
+		r := p.nextRune()
+		if r == eof {
+			if !p.skipping {
+				warn.Printf("unexpected EOF when evaluating token %v", exprString(x))
+			}
+			p.fail(bak, exprString(x), "EOF")
+			p.pos = bak
+			return false
+		}
+		if r != q {
+			if !p.skipping {
+				warn.Printf("   mismatch %q (expected %q)", r, q)
+			}
+			p.fail(bak, exprString(x), p.quoteInput(bak, utf8.RuneCountInString(x.String)))
+			p.pos = bak
+			return false
+		}
+		dbg.Printf("   match %q", r)
+	}
+	p.matchTerm(bak)
+	return true
+}
+
+// evalRange evaluates a range of characters. Must be valid.
+//
+//	a … z
+func (p *parser) evalRange(x *ebnf.Range) bool {
+	dbg.Println("evalRange:", exprString(x))
+	from, _ := utf8.DecodeRuneInString(x.Begin.String)
+	to, _ := utf8.DecodeRuneInString(x.End.String)
+	bak := p.pos
+	r := p.nextRune()
+	if r == eof {
+		if !p.skipping {
+			warn.Printf("unexpected EOF when evaluating range %v", exprString(x))
+		}
+		p.fail(bak, exprString(x), "EOF")
+		return false
+	}
+	ret := from <= r && r <= to
+	if ret {
+		dbg.Printf("   match: %q in %q … %q", r, from, to)
+		p.matchTerm(bak)
+	} else {
+		if !p.skipping {
+			warn.Printf("   mismatch: %q not in %q … %q", r, from, to)
+		}
+		p.fail(bak, exprString(x), p.quoteInput(bak, 1))
+	}
+	return ret
+}
+
+// evalGroup evaluates a grouped expression. Must be valid.
+//
+//	( body )
+func (p *parser) evalGroup(x *ebnf.Group) bool {
+	dbg.Println("evalGroup:", exprString(x))
+	return p.evalExpr(x.Body)
+}
+
+// evalOpt evaluates an optional expression. Must have zero or one valid
+// expressions.
+//
+//	[ body ]
+func (p *parser) evalOpt(x *ebnf.Option) bool {
+	dbg.Println("evalOpt:", exprString(x))
+	// store position, parse tree nodes, terminal matches and streaming parse
+	// events, and try to parse the optional.
+	bak, nodes, matches, events := p.pos, len(p.nodes), len(p.matches), p.saveEvents()
+	// EOF is valid in option
+	if !p.atEOF() && !p.evalExpr(x.Body) {
+		// invalid body is valid in option
+		// reset position, parse tree nodes and terminal matches
+		p.pos, p.nodes, p.matches = bak, p.nodes[:nodes], p.matches[:matches]
+		p.restoreEvents(events, false)
+		return true
+	}
+	p.restoreEvents(events, true)
+	return true
+}
+
+// evalRep evaluates a repeated expression. Must have zero or more valid
+// expressions.
+//
+//	{ body }
+func (p *parser) evalRep(x *ebnf.Repetition) bool {
+	dbg.Println("evalRep:", exprString(x))
+	// EOF is valid in repetition
+	for !p.atEOF() {
+		// store position, parse tree nodes, terminal matches and streaming parse
+		// events, and try to parse a repetition.
+		bak, nodes, matches, events := p.pos, len(p.nodes), len(p.matches), p.saveEvents()
+		dbg.Println("bak:", bak)
+		if !p.evalExpr(x.Body) {
+			// invalid body is valid in repetition
+			// reset position, parse tree nodes and terminal matches
+			dbg.Println("p.pos:", p.pos)
+			p.pos, p.nodes, p.matches = bak, p.nodes[:nodes], p.matches[:matches]
+			p.restoreEvents(events, false)
+			break
+		}
+		p.restoreEvents(events, true)
+		if p.pos == bak {
+			// body matched empty input; stop to prevent infinite loop.
+			break
+		}
+	}
+	return true
+}
+
+// ### [ Helper functions ] ####################################################
+
+// exprString returns the string representation of the given EBNF expression.
+func exprString(x ebnf.Expression) string {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+		return ""
+	case *ebnf.Production:
+		return fmt.Sprintf("%v = %v .", exprString(x.Name), exprString(x.Expr))
+	case ebnf.Alternative:
+		buf := strings.Builder{}
+		for i, e := range x {
+			if i != 0 {
+				buf.WriteString(" | ")
+			}
+			buf.WriteString(exprString(e))
+		}
+		return buf.String()
+	case ebnf.Sequence:
+		buf := strings.Builder{}
+		for i, e := range x {
+			if i != 0 {
+				buf.WriteString(" ")
+			}
+			buf.WriteString(exprString(e))
+		}
+		return buf.String()
+	case *ebnf.Name:
+		return x.String
+	case *ebnf.Token:
+		return fmt.Sprintf("%q", x.String)
+	case *ebnf.Range:
+		return fmt.Sprintf("%v … %v", exprString(x.Begin), exprString(x.End))
+	case *ebnf.Group:
+		return fmt.Sprintf("( %v )", exprString(x.Body))
+	case *ebnf.Option:
+		return fmt.Sprintf("[ %v ]", exprString(x.Body))
+	case *ebnf.Repetition:
+		return fmt.Sprintf("{ %v }", exprString(x.Body))
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// eof signals end of input.
+const eof rune = -1
+
+// atEOF reports whether the end of input has been reached.
+func (p *parser) atEOF() bool {
+	p.peek(1)
+	return p.pos >= len(p.input)
+}
+
+// nextRune returns the next Unicode rune of the input source.
+func (p *parser) nextRune() rune {
+	if p.atEOF() {
+		dbg.Println("eof")
+		return eof
+	}
+	p.peekRune()
+	r, size := utf8.DecodeRune(p.input[p.pos:])
+	p.pos += size
+	dbg.Println("pos:", p.pos, len(p.input))
+	return r
+}
@@ -0,0 +1,326 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// parseTestGrammar parses the given EBNF grammar source.
+func parseTestGrammar(t testing.TB, src string) ebnf.Grammar {
+	t.Helper()
+	grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unable to parse grammar %q; %v", src, err)
+	}
+	return grammar
+}
+
+// testConfig returns the runtime parser configuration of the given grammar.
+func testConfig(grammar ebnf.Grammar) *Config {
+	return &Config{
+		SkipRule: "skip",
+		Tree:     true,
+		First:    analysis.First(grammar),
+		Nullable: analysis.Nullable(grammar),
+	}
+}
+
+// parseTest parses the given input from the given start production rule using
+// the given configuration, and returns the root node of the parse tree and the
+// parse errors.
+func parseTest(t testing.TB, grammar ebnf.Grammar, start, input string, conf *Config) (*ParseNode, []ParseError) {
+	t.Helper()
+	root, errs, _, err := Parse(context.Background(), grammar, []byte(input), conf, start)
+	if err != nil {
+		t.Fatalf("unable to parse %q; %+v", input, err)
+	}
+	return root, errs
+}
+
+func TestNodeText(t *testing.T) {
+	const src = `
+Expr = Term { "+" Term } .
+Term = number | "(" Expr ")" .
+number = digit { digit } .
+digit = "0" … "9" .
+skip = " " .
+`
+	grammar := parseTestGrammar(t, src)
+	root, errs := parseTest(t, grammar, "Expr", "1 + (2+3)", testConfig(grammar))
+	if len(errs) > 0 {
+		t.Fatalf("unable to parse input; %v", errs[0])
+	}
+	golden := []struct {
+		node *ParseNode
+		want string
+	}{
+		{node: root, want: "1 + (2+3)"},
+		{node: root.Children[0], want: "1"},
+		{node: root.Children[1], want: "(2+3)"},
+	}
+	for _, g := range golden {
+		if got := g.node.TextString(); got != g.want {
+			t.Errorf("%s: text mismatch; expected %q, got %q", g.node.Name, g.want, got)
+		}
+	}
+	buf, err := json.Marshal(root.Children[1])
+	if err != nil {
+		t.Fatalf("unable to marshal parse tree node; %v", err)
+	}
+	if !strings.Contains(string(buf), `"text":"(2+3)"`) {
+		t.Errorf("text missing from JSON output %s", buf)
+	}
+}
+
+func TestFirstSetStats(t *testing.T) {
+	const src = `
+Stmts = { Stmt } .
+Stmt = "if" Expr Stmt | "while" Expr Stmt | "return" Expr ";" | "{" Stmts "}" | "break" ";" | Expr ";" .
+Expr = number | ident | "(" Expr ")" .
+number = digit { digit } .
+ident = letter { letter } .
+digit = "0" … "9" .
+letter = "a" … "z" .
+skip = " " | "\t" | "\n" .
+`
+	const input = `
+while (x) {
+	if 1 return 2;
+	break;
+	x;
+	while y { return (z); }
+}
+`
+	grammar := parseTestGrammar(t, src)
+	root, errs, stats, err := Parse(context.Background(), grammar, []byte(input), testConfig(grammar), "Stmts")
+	if err != nil {
+		t.Fatalf("unable to parse input; %+v", err)
+	}
+	if len(errs) > 0 {
+		t.Fatalf("unable to parse input; %v", errs[0])
+	}
+	if want := len(strings.TrimRight(input, "\n")); root.End != want {
+		t.Errorf("end offset mismatch; expected %d, got %d", want, root.End)
+	}
+	if stats.Total == 0 {
+		t.Fatalf("no alternatives considered")
+	}
+	if pruned := 100 * stats.Pruned / stats.Total; pruned < 50 {
+		t.Errorf("first-set pruning below 50%%; pruned %d of %d alternatives (%d%%)", stats.Pruned, stats.Total, pruned)
+	}
+}
+
+func TestFirstSetMutualRecursion(t *testing.T) {
+	golden := []struct {
+		grammar string
+		// Expected FIRST sets, indexed by production name.
+		want map[string]string
+	}{
+		{
+			grammar: `a = b . b = a .`,
+			want:    map[string]string{"a": "", "b": ""},
+		},
+		{
+			grammar: `a = b | "x" . b = a | "y" .`,
+			want:    map[string]string{"a": `"x" "y"`, "b": `"x" "y"`},
+		},
+		{
+			grammar: `a = [ b ] "x" . b = c . c = [ a ] "y" .`,
+			want:    map[string]string{"a": `"x" "y"`, "b": `"x" "y"`, "c": `"x" "y"`},
+		},
+	}
+	for _, g := range golden {
+		grammar := parseTestGrammar(t, g.grammar)
+		first := analysis.First(grammar)
+		for name, want := range g.want {
+			var ts []string
+			for _, t := range first[name].Sorted() {
+				ts = append(ts, t.String())
+			}
+			if got := strings.Join(ts, " "); got != want {
+				t.Errorf("%q: first set mismatch of %q; expected {%s}, got {%s}", g.grammar, name, want, got)
+			}
+		}
+	}
+}
+
+func TestEOFInRepetition(t *testing.T) {
+	const src = `
+List = { Item } .
+Item = ident [ "," ] .
+ident = letter { letter } .
+letter = "a" … "z" .
+skip = " " | "\n" .
+`
+	grammar := parseTestGrammar(t, src)
+	golden := []struct {
+		input string
+	}{
+		{input: ""},
+		{input: "a"},
+		{input: "ab cd"},
+		{input: "ab, cd,"},
+		// trailing skipped whitespace.
+		{input: "ab cd \n"},
+		{input: "ab,\n"},
+	}
+	for _, g := range golden {
+		root, errs := parseTest(t, grammar, "List", g.input, testConfig(grammar))
+		if len(errs) > 0 {
+			t.Errorf("%q: unable to parse input; %v", g.input, errs[0])
+			continue
+		}
+		if want := len(strings.TrimRight(g.input, " \n")); root.End != want {
+			t.Errorf("%q: end offset mismatch; expected %d, got %d", g.input, want, root.End)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	const src = `
+List = { Item } .
+Item = ident | "(" List ")" .
+ident = letter { letter } .
+letter = "a" … "z" .
+skip = " " .
+`
+	grammar := parseTestGrammar(t, src)
+	input := []byte(strings.Repeat("(ab cd (ef)) ", 100))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// cancel parsing after 100 checks of the context.
+	ctx = &cancelAfter{Context: ctx, n: 100, cancel: cancel}
+	conf := testConfig(grammar)
+	_, _, _, err := Parse(ctx, grammar, input, conf, "List")
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("error mismatch; expected %v, got %v", context.Canceled, err)
+	}
+}
+
+// cancelAfter is a context which is cancelled after a given number of calls to
+// Err.
+type cancelAfter struct {
+	context.Context
+	// Remaining number of calls to Err before cancellation.
+	n int
+	// Cancels the context.
+	cancel context.CancelFunc
+}
+
+// Err returns the error of the context, after cancelling the context once the
+// given number of calls to Err have been made.
+func (ctx *cancelAfter) Err() error {
+	ctx.n--
+	if ctx.n == 0 {
+		ctx.cancel()
+	}
+	return ctx.Context.Err()
+}
+
+func TestEvalSeqBacktrack(t *testing.T) {
+	// position is restored after a partial sequence match.
+	grammar := parseTestGrammar(t, `S = "a" "b" "c" . skip = " " .`)
+	p := &parser{
+		ctx:      context.Background(),
+		grammar:  grammar,
+		skipRule: "skip",
+		input:    []byte("a b d"),
+		altFirst: make(map[*ebnf.Expression]analysis.Set),
+	}
+	if p.evalSeq(grammar["S"].Expr.(ebnf.Sequence)) {
+		t.Fatalf("expected partial sequence match to fail")
+	}
+	if p.pos != 0 {
+		t.Errorf("position mismatch after partial sequence match; expected 0, got %d", p.pos)
+	}
+	// sequences of alternatives and repetitions are backtracked.
+	golden := []struct {
+		grammar string
+		input   string
+	}{
+		{grammar: `S = "a" "b" "c" | "a" "b" "d" . skip = " " .`, input: "a b d"},
+		{grammar: `S = { "a" "b" } "a" "c" . skip = " " .`, input: "a b a b a c"},
+		{grammar: `S = [ "a" "b" "c" ] "a" "b" . skip = " " .`, input: "a b"},
+	}
+	for _, g := range golden {
+		grammar := parseTestGrammar(t, g.grammar)
+		root, errs := parseTest(t, grammar, "S", g.input, testConfig(grammar))
+		if len(errs) > 0 {
+			t.Errorf("%q: unable to parse %q; %v", g.grammar, g.input, errs[0])
+			continue
+		}
+		if root.End != len(g.input) {
+			t.Errorf("%q: end offset mismatch of %q; expected %d, got %d", g.grammar, g.input, len(g.input), root.End)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	golden := []struct {
+		grammar string
+		input   string
+		// Expected events after applying retract events.
+		want []string
+	}{
+		// backtracking of alternatives.
+		{
+			grammar: `S = A "x" | A "y" . A = "a" .`,
+			input:   "ay",
+			want:    []string{"open S 0", "open A 0", "close A 1", "close S 2"},
+		},
+		// backtracking of repetitions.
+		{
+			grammar: `S = { A "," } A . A = "a" . skip = " " .`,
+			input:   "a, a",
+			want:    []string{"open S 0", "open A 0", "close A 1", "open A 3", "close A 4", "close S 4"},
+		},
+		// failed parse.
+		{
+			grammar: `S = A "x" . A = "a" .`,
+			input:   "ay",
+			want:    nil,
+		},
+	}
+	for _, g := range golden {
+		grammar := parseTestGrammar(t, g.grammar)
+		conf := testConfig(grammar)
+		buf := &bytes.Buffer{}
+		conf.Stream = NewEventStream(buf)
+		parseTest(t, grammar, "S", g.input, conf)
+		if err := conf.Stream.Flush(); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		// Apply retract events.
+		var events []streamEvent
+		dec := json.NewDecoder(buf)
+		for dec.More() {
+			var e streamEvent
+			if err := dec.Decode(&e); err != nil {
+				t.Fatalf("unable to decode streaming parse event; %v", err)
+			}
+			if e.Event == "retract" {
+				events = events[:e.Seq]
+				continue
+			}
+			if e.Seq != len(events) {
+				t.Errorf("%q: sequence number mismatch of %q; expected %d, got %d", g.grammar, g.input, len(events), e.Seq)
+			}
+			events = append(events, e)
+		}
+		var got []string
+		for _, e := range events {
+			got = append(got, fmt.Sprintf("%s %s %d", e.Event, e.Prod, e.Offset))
+		}
+		if strings.Join(got, ", ") != strings.Join(g.want, ", ") {
+			t.Errorf("%q: events mismatch of %q; expected %q, got %q", g.grammar, g.input, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mewmew/speak/analysis"
+	"golang.org/x/exp/ebnf"
+)
+
+// Explain returns a natural language explanation of the given parse errors,
+// with one sentence per production rule in which parsing failed.
+//
+// When parsing failed at the start of a production rule, the expected input is
+// enumerated from the FIRST set of the outermost such production rule;
+// otherwise, the expected input of the parse errors is used.
+func Explain(grammar ebnf.Grammar, errs []ParseError) []string {
+	var first map[string]analysis.Set
+	// Group parse errors by production rule, in order of occurrence.
+	var prods []string
+	expected := make(map[string][]string)
+	found := make(map[string]ParseError)
+	for _, e := range errs {
+		prod := e.Context
+		if len(e.outer) > 0 {
+			prod = e.outer
+		}
+		if _, ok := found[prod]; !ok {
+			prods = append(prods, prod)
+			found[prod] = e
+			if len(e.outer) > 0 {
+				if first == nil {
+					first = analysis.First(grammar)
+				}
+				for _, t := range first[prod].Sorted() {
+					expected[prod] = append(expected[prod], t.String())
+				}
+			}
+		}
+		if len(e.outer) == 0 && !contains(expected[prod], e.Expected) {
+			expected[prod] = append(expected[prod], e.Expected)
+		}
+	}
+	var sentences []string
+	for _, prod := range prods {
+		e := found[prod]
+		want := expected[prod]
+		sentence := fmt.Sprintf("At byte %d (line %d, col %d): expected ", e.Offset, e.Line, e.Col)
+		if len(want) > 1 {
+			sentence += "one of "
+		}
+		sentence += strings.Join(want, ", ")
+		if len(prod) > 0 {
+			sentence += fmt.Sprintf(" (from production %s)", prod)
+		}
+		sentence += fmt.Sprintf(", but found %s.", e.Got)
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// contains reports whether the given string slice contains s.
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
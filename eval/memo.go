@@ -0,0 +1,178 @@
+package eval
+
+import (
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+)
+
+// Memo is a cache of the results of syntactic production rules evaluated at
+// offsets of the input source, used to reuse results across parses of edited
+// input.
+//
+// Each result records the range of input examined by the evaluation of the
+// production rule, including lookahead. After an edit of the input, results
+// which examined the edited range are discarded, and results after the edited
+// range are shifted; the remaining results are valid for the edited input.
+type Memo struct {
+	// Results indexed by production name and offset.
+	results map[memoKey]*memoResult
+}
+
+// NewMemo returns a new empty cache of production rule results.
+func NewMemo() *Memo {
+	return &Memo{
+		results: make(map[memoKey]*memoResult),
+	}
+}
+
+// Edit updates the cached results for an edit of the input source, which
+// replaces the deleteLen bytes at offset off with insertLen bytes.
+func (m *Memo) Edit(off, deleteLen, insertLen int) {
+	delta := insertLen - deleteLen
+	results := make(map[memoKey]*memoResult)
+	for key, r := range m.results {
+		switch {
+		case r.examined <= off:
+			// result before edited range.
+			results[key] = r
+		case key.offset >= off+deleteLen:
+			// result after edited range.
+			key.offset += delta
+			results[key] = r.shift(delta)
+		default:
+			// result overlapping edited range; discard.
+		}
+	}
+	m.results = results
+}
+
+// memoKey is the key of a production rule result.
+type memoKey struct {
+	// Production name.
+	name string
+	// Offset of the input source at which the production rule is evaluated.
+	offset int
+}
+
+// memoResult is the result of a production rule evaluated at an offset of the
+// input source.
+type memoResult struct {
+	// Production rule matched.
+	ok bool
+	// Offset of the input source after evaluation.
+	end int
+	// End offset (exclusive) of the input examined during evaluation.
+	examined int
+	// Parse tree node of the production rule; nil if not matched.
+	node *ParseNode
+	// Most recently skipped whitespace and comments after evaluation, if
+	// skipped during evaluation.
+	skipped *[2]int
+}
+
+// shift returns a copy of the result with offsets shifted by delta. The matched
+// source text of shifted parse tree nodes is cleared, to be filled in from the
+// edited input source on reuse.
+func (r *memoResult) shift(delta int) *memoResult {
+	s := &memoResult{
+		ok:       r.ok,
+		end:      r.end + delta,
+		examined: r.examined + delta,
+	}
+	if r.node != nil {
+		s.node = shiftNode(r.node, delta)
+	}
+	if r.skipped != nil {
+		s.skipped = &[2]int{r.skipped[0] + delta, r.skipped[1] + delta}
+	}
+	return s
+}
+
+// memoize reports whether production rules are memoized at the current point
+// of evaluation. Only production rules recorded in the parse tree are
+// memoized, as their results are independent of the enclosing production rule.
+func (p *parser) memoize() bool {
+	if p.memo == nil || p.skipping {
+		return false
+	}
+	return len(p.stack) == 0 || !analysis.IsLexical(p.stack[len(p.stack)-1].name)
+}
+
+// reuse reuses the given cached production rule result, and reports whether
+// the production rule matched.
+func (p *parser) reuse(r *memoResult) bool {
+	p.pos = r.end
+	if r.node != nil {
+		fillText(r.node, p.input)
+		p.nodes = append(p.nodes, r.node)
+	}
+	if r.skipped != nil {
+		p.skipped = *r.skipped
+	}
+	if r.examined > p.examined {
+		p.examined = r.examined
+	}
+	return r.ok
+}
+
+// peek records that n bytes of input from the current position are examined.
+func (p *parser) peek(n int) {
+	if end := p.pos + n; end > p.examined {
+		p.examined = end
+	}
+}
+
+// peekRune records that the next Unicode rune of the input is examined.
+func (p *parser) peekRune() {
+	if p.pos >= len(p.input) {
+		p.peek(1)
+		return
+	}
+	r, size := utf8.DecodeRune(p.input[p.pos:])
+	if r == utf8.RuneError {
+		// invalid encodings may be examined beyond the decoded size.
+		size = utf8.UTFMax
+	}
+	p.peek(size)
+}
+
+// peekSet records that the input examined when matching the given terminals at
+// the current position is examined.
+func (p *parser) peekSet(set analysis.Set) {
+	for t := range set {
+		if len(t.Token) > 0 {
+			p.peek(len(t.Token))
+		} else {
+			p.peekRune()
+		}
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// shiftNode returns a copy of the given parse tree with offsets shifted by
+// delta, and the matched source text cleared.
+func shiftNode(node *ParseNode, delta int) *ParseNode {
+	n := &ParseNode{
+		Name:  node.Name,
+		Start: node.Start + delta,
+		End:   node.End + delta,
+	}
+	for _, child := range node.Children {
+		n.Children = append(n.Children, shiftNode(child, delta))
+	}
+	return n
+}
+
+// fillText fills in the cleared matched source text of the given parse tree
+// from the input source.
+func fillText(node *ParseNode, input []byte) {
+	if node.Text != nil {
+		return
+	}
+	node.Text = input[node.Start:node.End]
+	for _, child := range node.Children {
+		fillText(child, input)
+	}
+}
@@ -0,0 +1,40 @@
+package eval
+
+import (
+	"encoding/json"
+)
+
+// ParseNode is a node of the parse tree, corresponding to a successfully
+// evaluated production rule. Lexical production rules are leaf nodes.
+type ParseNode struct {
+	// Production name.
+	Name string `json:"name"`
+	// Start offset in the input source.
+	Start int `json:"start"`
+	// End offset in the input source.
+	End int `json:"end"`
+	// Child nodes of syntactic production rules, in order of occurrence.
+	Children []*ParseNode `json:"children,omitempty"`
+	// Matched source text; the input source between the start and end offset,
+	// including tokens and skipped whitespace and comments between child nodes.
+	Text []byte `json:"-"`
+}
+
+// TextString returns the matched source text of the parse tree node.
+func (node *ParseNode) TextString() string {
+	return string(node.Text)
+}
+
+// MarshalJSON returns the JSON encoding of the parse tree node, with the
+// matched source text encoded as a string.
+func (node *ParseNode) MarshalJSON() ([]byte, error) {
+	type plainNode ParseNode
+	v := struct {
+		*plainNode
+		Text string `json:"text"`
+	}{
+		plainNode: (*plainNode)(node),
+		Text:      node.TextString(),
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,36 @@
+package eval
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// termMatch is a terminal match of the input source.
+type termMatch struct {
+	// Start offset of the terminal match in the input source.
+	offset int
+	// Name of the innermost production rule of the terminal match.
+	prod string
+}
+
+// matchTerm records a terminal match at the given offset of the input source,
+// if output maps are enabled. Terminals matched by the skip production rule are
+// not recorded.
+func (p *parser) matchTerm(offset int) {
+	if p.outputMap == nil || p.skipping || len(p.stack) == 0 {
+		return
+	}
+	p.matches = append(p.matches, termMatch{offset: offset, prod: p.stack[len(p.stack)-1].name})
+}
+
+// writeOutputMap writes the recorded terminal matches as CSV rows of offset
+// and production name.
+func (p *parser) writeOutputMap() error {
+	for _, m := range p.matches {
+		if err := p.outputMap.Write([]string{strconv.Itoa(m.offset), m.prod}); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
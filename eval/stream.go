@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// EventStream is a writer of streaming parse events as JSON lines.
+//
+// Events are written as soon as production rules of the parse tree are opened
+// and closed, and each event is assigned a sequence number. Events undone by
+// backtracking (i.e. events of failed alternatives, options, repetitions and
+// abandoned start production rules) are withdrawn by a retract event, which
+// discards all events with a sequence number greater than or equal to the one
+// of the retract event.
+//
+//	{"event":"open","prod":"Expr","offset":0,"seq":0}
+//	{"event":"open","prod":"Call","offset":0,"seq":1}
+//	{"event":"retract","seq":1}
+//	{"event":"open","prod":"Term","offset":0,"seq":1}
+//
+// After a retract event, sequence numbers are reused from the retracted
+// sequence number. Thus, the sequence number of an event is its index within
+// the events not yet withdrawn, and consumers only need to keep the events of
+// the production rules currently open to stay in O(depth) memory.
+type EventStream struct {
+	// Buffered output writer.
+	w *bufio.Writer
+	// JSON encoder of output writer.
+	enc *json.Encoder
+	// Sequence number of the next event.
+	seq int
+}
+
+// NewEventStream returns a new writer of streaming parse events to w.
+func NewEventStream(w io.Writer) *EventStream {
+	bw := bufio.NewWriter(w)
+	return &EventStream{
+		w:   bw,
+		enc: json.NewEncoder(bw),
+	}
+}
+
+// Flush writes any buffered streaming parse events to the underlying writer.
+func (s *EventStream) Flush() error {
+	if err := s.w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// streamEvent is a streaming parse event, emitted as production rules of the
+// parse tree are opened and closed.
+type streamEvent struct {
+	// Event kind (open or close).
+	Event string `json:"event"`
+	// Production name.
+	Prod string `json:"prod"`
+	// Offset in input source.
+	Offset int `json:"offset"`
+	// Sequence number of event.
+	Seq int `json:"seq"`
+}
+
+// retractEvent is a streaming parse event which withdraws the events with a
+// sequence number greater than or equal to Seq.
+type retractEvent struct {
+	// Event kind (retract).
+	Event string `json:"event"`
+	// Sequence number of first withdrawn event.
+	Seq int `json:"seq"`
+}
+
+// emit writes a streaming parse event of the given production rule, if
+// streaming is enabled.
+func (p *parser) emit(event, prod string, offset int) {
+	if p.stream == nil {
+		return
+	}
+	p.writeEvent(streamEvent{Event: event, Prod: prod, Offset: offset, Seq: p.stream.seq})
+	p.stream.seq++
+}
+
+// retract withdraws the streaming parse events with a sequence number greater
+// than or equal to seq, if any.
+func (p *parser) retract(seq int) {
+	if p.stream == nil || p.stream.seq <= seq {
+		return
+	}
+	p.writeEvent(retractEvent{Event: "retract", Seq: seq})
+	p.stream.seq = seq
+}
+
+// writeEvent writes the given streaming parse event as a JSON line.
+func (p *parser) writeEvent(e interface{}) {
+	if err := p.stream.enc.Encode(e); err != nil {
+		panic(abort{err: errors.WithStack(err)})
+	}
+}
+
+// saveEvents marks a backtrack point, and returns the sequence number of the
+// next streaming parse event to retract to on backtracking.
+func (p *parser) saveEvents() int {
+	if p.stream == nil {
+		return 0
+	}
+	return p.stream.seq
+}
+
+// restoreEvents ends the backtrack point of the given sequence number of
+// streaming parse events; retracting the events emitted since if the
+// evaluation failed (i.e. backtracking).
+func (p *parser) restoreEvents(seq int, ok bool) {
+	if !ok {
+		p.retract(seq)
+	}
+}
@@ -0,0 +1,153 @@
+// Package format implements formatting of EBNF grammars.
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// Sprint returns the EBNF text of the given grammar.
+func Sprint(grammar ebnf.Grammar) string {
+	buf := &strings.Builder{}
+	if err := Fprint(buf, grammar); err != nil {
+		// unreachable; strings.Builder never returns an error.
+		panic(err)
+	}
+	return buf.String()
+}
+
+// Fprint writes the EBNF text of the given grammar to w, with one production
+// per line. Productions are ordered by source position, and by name for
+// productions with the same position (e.g. those created programmatically).
+func Fprint(w io.Writer, grammar ebnf.Grammar) error {
+	bw := bufio.NewWriter(w)
+	for _, prod := range Prods(grammar) {
+		if _, err := fmt.Fprintln(bw, SprintProd(prod)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// FprintAligned writes the EBNF text of the given grammar to w, with one
+// production per line and the "=" signs aligned by right-padding production
+// names with spaces, as in the Go specification. Productions are ordered as by
+// Fprint.
+//
+// The "=" signs are aligned to the longest production name of at most maxLen
+// characters, or to the longest production name if maxLen <= 0. The bodies of
+// productions with longer names are wrapped onto the next line and indented by
+// a tab.
+//
+//	Expr  = Term { "+" Term } .
+//	Term  = Factor { "*" Factor } .
+//	VeryLongProductionName =
+//	    Expr .
+func FprintAligned(w io.Writer, grammar ebnf.Grammar, maxLen int) error {
+	prods := Prods(grammar)
+	width := 0
+	for _, prod := range prods {
+		n := utf8.RuneCountInString(prod.Name.String)
+		if n > width && (maxLen <= 0 || n <= maxLen) {
+			width = n
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, prod := range prods {
+		name := prod.Name.String
+		body := "."
+		if prod.Expr != nil {
+			body = SprintExpr(prod.Expr) + " ."
+		}
+		var line string
+		if n := utf8.RuneCountInString(name); n > width {
+			line = fmt.Sprintf("%s =\n\t%s", name, body)
+		} else {
+			line = fmt.Sprintf("%s%s = %s", name, strings.Repeat(" ", width-n), body)
+		}
+		if _, err := fmt.Fprintln(bw, line); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Prods returns the productions of the given grammar, ordered by source
+// position and name.
+func Prods(grammar ebnf.Grammar) []*ebnf.Production {
+	var prods []*ebnf.Production
+	for _, prod := range grammar {
+		prods = append(prods, prod)
+	}
+	sort.Slice(prods, func(i, j int) bool {
+		pi, pj := prods[i].Pos(), prods[j].Pos()
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		if pi.Offset != pj.Offset {
+			return pi.Offset < pj.Offset
+		}
+		return prods[i].Name.String < prods[j].Name.String
+	})
+	return prods
+}
+
+// SprintProd returns the EBNF text of the given production.
+//
+//	Name = Expr .
+func SprintProd(prod *ebnf.Production) string {
+	if prod.Expr == nil {
+		return fmt.Sprintf("%s = .", prod.Name.String)
+	}
+	return fmt.Sprintf("%s = %s .", prod.Name.String, SprintExpr(prod.Expr))
+}
+
+// SprintExpr returns the EBNF text of the given expression.
+func SprintExpr(x ebnf.Expression) string {
+	switch x := x.(type) {
+	case ebnf.Alternative:
+		var alts []string
+		for _, e := range x {
+			alts = append(alts, SprintExpr(e))
+		}
+		return strings.Join(alts, " | ")
+	case ebnf.Sequence:
+		var seq []string
+		for _, e := range x {
+			s := SprintExpr(e)
+			if _, ok := e.(ebnf.Alternative); ok {
+				// preserve precedence of alternatives within sequences.
+				s = fmt.Sprintf("( %s )", s)
+			}
+			seq = append(seq, s)
+		}
+		return strings.Join(seq, " ")
+	case *ebnf.Name:
+		return x.String
+	case *ebnf.Token:
+		return fmt.Sprintf("%q", x.String)
+	case *ebnf.Range:
+		return fmt.Sprintf("%s … %s", SprintExpr(x.Begin), SprintExpr(x.End))
+	case *ebnf.Group:
+		return fmt.Sprintf("( %s )", SprintExpr(x.Body))
+	case *ebnf.Option:
+		return fmt.Sprintf("[ %s ]", SprintExpr(x.Body))
+	case *ebnf.Repetition:
+		return fmt.Sprintf("{ %s }", SprintExpr(x.Body))
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
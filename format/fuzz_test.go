@@ -0,0 +1,46 @@
+package format
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/exp/ebnf"
+)
+
+// FuzzRoundTrip checks that grammars serialized by Sprint parse back into
+// grammars with the same EBNF text.
+func FuzzRoundTrip(f *testing.F) {
+	// Seed with the example grammars of the repository.
+	for _, path := range []string{"../cmd/speak/grammar.ebnf"} {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatalf("unable to read grammar; %v", err)
+		}
+		f.Add(src)
+	}
+	seeds := []string{
+		`A = .`,
+		`A = "a" | "b" "c" | ( "d" | "e" ) "f" .`,
+		`A = [ B ] { "x" B } . B = "0" … "9" .`,
+		"A = `raw\\string` \"\\n\\t\\\"\" \"…\" .",
+		`A = ( ( "a" ) ) [ [ "b" ] ] { { "c" } } .`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		grammar, err := ebnf.Parse("fuzz.ebnf", bytes.NewReader(src))
+		if err != nil {
+			t.Skip()
+		}
+		want := Sprint(grammar)
+		got, err := ebnf.Parse("fuzz.ebnf", bytes.NewReader([]byte(want)))
+		if err != nil {
+			t.Fatalf("unable to parse serialized grammar %q of %q; %v", want, src, err)
+		}
+		if s := Sprint(got); s != want {
+			t.Errorf("round-trip mismatch of %q; expected %q, got %q", src, want, s)
+		}
+	})
+}
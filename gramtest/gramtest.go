@@ -0,0 +1,314 @@
+// Package gramtest implements grammar-based property tests of the runtime
+// evaluator.
+//
+// Random strings of the language of a grammar are generated, and each string
+// is verified to be accepted by the runtime evaluator, and to be parsed the same
+// when re-parsed from the matched source text of its parse tree.
+package gramtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/eval"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// SkipRule is the name of the skip production rule (whitespace and comments)
+// of grammars checked by Check.
+const SkipRule = "skip"
+
+// Check generates n random strings of the language of the grammar from the
+// given start production rule, and verifies that for each string:
+//
+//  1. the runtime evaluator accepts the string; and
+//  2. re-parsing the matched source text of the parse tree regenerates the same
+//     parse tree.
+//
+// The strings are generated by testing/quick, using a grammar-guided random
+// walk biased by the FIRST sets of production rules. The test fails with the
+// generated string if any check fails.
+func Check(t *testing.T, grammar ebnf.Grammar, start string, n int) {
+	t.Helper()
+	if err := check(grammar, start, n, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatalf("%+v", err)
+	}
+}
+
+// check generates n random strings of the language of the grammar from the
+// given start production rule using the given source of randomness, and
+// verifies that the runtime evaluator accepts each string and parses it the
+// same when re-parsed.
+func check(grammar ebnf.Grammar, start string, n int, rnd *rand.Rand) error {
+	if err := analysis.Verify(grammar, SkipRule, start); err != nil {
+		return errors.Wrapf(err, "invalid grammar")
+	}
+	if cycles := analysis.DetectLeftRecursion(grammar); len(cycles) > 0 {
+		return errors.Errorf("unable to evaluate left-recursive grammar; left-recursive productions %s", strings.Join(cycles[0], ", "))
+	}
+	conf := &eval.Config{
+		SkipRule: SkipRule,
+		Tree:     true,
+		First:    analysis.First(grammar),
+		Nullable: analysis.Nullable(grammar),
+	}
+	g := newGenerator(grammar, conf.First, conf.Nullable)
+	// failure records the reason of the failed check.
+	var failure error
+	f := func(input string) bool {
+		if failure = checkInput(grammar, start, conf, input); failure != nil {
+			return false
+		}
+		return true
+	}
+	config := &quick.Config{
+		MaxCount: n,
+		Rand:     rnd,
+		Values: func(values []reflect.Value, rnd *rand.Rand) {
+			values[0] = reflect.ValueOf(g.generate(start, rnd))
+		},
+	}
+	if err := quick.Check(f, config); err != nil {
+		if e, ok := err.(*quick.CheckError); ok {
+			return errors.Wrapf(failure, "check #%d failed for input %q", e.Count, e.In[0])
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// checkInput verifies that the runtime evaluator accepts the given input, and
+// that re-parsing the matched source text of the parse tree regenerates the
+// same parse tree.
+func checkInput(grammar ebnf.Grammar, start string, conf *eval.Config, input string) error {
+	root, err := parse(grammar, start, conf, input)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	text := root.TextString()
+	again, err := parse(grammar, start, conf, text)
+	if err != nil {
+		return errors.Wrapf(err, "unable to re-parse matched source text %q", text)
+	}
+	if want, got := treeString(root, root.Start), treeString(again, again.Start); got != want {
+		return errors.Errorf("parse tree mismatch of matched source text %q; expected %s, got %s", text, want, got)
+	}
+	return nil
+}
+
+// parse parses the given input using the runtime evaluator, and returns the
+// root node of the parse tree.
+func parse(grammar ebnf.Grammar, start string, conf *eval.Config, input string) (*eval.ParseNode, error) {
+	root, errs, _, err := eval.Parse(context.Background(), grammar, []byte(input), conf, start)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Errorf("runtime evaluator rejected input; %v", errs[0])
+	}
+	return root, nil
+}
+
+// treeString returns the string representation of the given parse tree, with
+// offsets relative to the given base offset.
+func treeString(node *eval.ParseNode, base int) string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%s[%d:%d]", node.Name, node.Start-base, node.End-base)
+	if len(node.Children) > 0 {
+		var children []string
+		for _, child := range node.Children {
+			children = append(children, treeString(child, base))
+		}
+		fmt.Fprintf(buf, "(%s)", strings.Join(children, " "))
+	}
+	return buf.String()
+}
+
+// ### [ Generator ] ###########################################################
+
+// Expansion depth after which the shortest expansions are used.
+const maxDepth = 10
+
+// inf denotes an infinite expansion height.
+const inf = 1 << 30
+
+// generator generates random strings of the language of a grammar by a
+// grammar-guided random walk.
+type generator struct {
+	// EBNF grammar.
+	grammar ebnf.Grammar
+	// FIRST sets of production rules.
+	first map[string]analysis.Set
+	// Nullable production rules.
+	nullable map[string]bool
+	// Minimum expansion height of each production rule.
+	height map[string]int
+	// Output of the current walk.
+	buf strings.Builder
+}
+
+// newGenerator returns a new generator of random strings of the language of
+// the given grammar.
+func newGenerator(grammar ebnf.Grammar, first map[string]analysis.Set, nullable map[string]bool) *generator {
+	g := &generator{
+		grammar:  grammar,
+		first:    first,
+		nullable: nullable,
+		height:   make(map[string]int),
+	}
+	// Iterate until a fixed point is reached.
+	for name := range grammar {
+		g.height[name] = inf
+	}
+	for changed := true; changed; {
+		changed = false
+		for name, prod := range grammar {
+			if h := g.exprHeight(prod.Expr); h != inf && h+1 < g.height[name] {
+				g.height[name] = h + 1
+				changed = true
+			}
+		}
+	}
+	return g
+}
+
+// generate returns a random string derived from the given start production
+// rule.
+func (g *generator) generate(start string, rnd *rand.Rand) string {
+	g.buf.Reset()
+	g.name(start, 0, rnd)
+	return g.buf.String()
+}
+
+// name generates a random string derived from the given production rule.
+// Whitespace and comments of the skip production rule separate the elements of
+// syntactic production rules.
+func (g *generator) name(name string, depth int, rnd *rand.Rand) {
+	prod := g.grammar[name]
+	if !analysis.IsLexical(name) {
+		g.separate(rnd)
+	}
+	g.expr(prod.Expr, analysis.IsLexical(name), depth+1, rnd)
+}
+
+// expr generates a random string derived from the given expression.
+func (g *generator) expr(x ebnf.Expression, lexical bool, depth int, rnd *rand.Rand) {
+	switch x := x.(type) {
+	case nil:
+		// empty expression.
+	case ebnf.Alternative:
+		g.expr(x[g.pickAlt(x, depth, rnd)], lexical, depth, rnd)
+	case ebnf.Sequence:
+		for _, e := range x {
+			g.expr(e, lexical, depth, rnd)
+		}
+	case *ebnf.Name:
+		g.name(x.String, depth, rnd)
+	case *ebnf.Token:
+		if !lexical {
+			g.separate(rnd)
+		}
+		g.buf.WriteString(x.String)
+	case *ebnf.Range:
+		begin, _ := utf8.DecodeRuneInString(x.Begin.String)
+		end, _ := utf8.DecodeRuneInString(x.End.String)
+		g.buf.WriteRune(begin + rune(rnd.Intn(int(end-begin)+1)))
+	case *ebnf.Group:
+		g.expr(x.Body, lexical, depth, rnd)
+	case *ebnf.Option:
+		if depth <= maxDepth && rnd.Intn(2) == 0 {
+			g.expr(x.Body, lexical, depth, rnd)
+		}
+	case *ebnf.Repetition:
+		if depth <= maxDepth {
+			for n := rnd.Intn(3); n > 0; n-- {
+				g.expr(x.Body, lexical, depth, rnd)
+			}
+		}
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
+
+// separate generates a random separator between the elements of syntactic
+// production rules, derived from the skip production rule. A separator is
+// always generated, as adjacent elements (e.g. two identifiers) could otherwise
+// be matched as one.
+func (g *generator) separate(rnd *rand.Rand) {
+	skip, ok := g.grammar[SkipRule]
+	if !ok {
+		return
+	}
+	g.expr(skip.Expr, true, maxDepth, rnd)
+}
+
+// pickAlt returns the index of the alternative to expand; the shortest
+// alternative if the maximum depth has been exceeded, or a random alternative
+// otherwise. Random alternatives are weighted by the size of their FIRST sets,
+// so that the terminals which may begin the alternatives are equally likely.
+func (g *generator) pickAlt(x ebnf.Alternative, depth int, rnd *rand.Rand) int {
+	if depth > maxDepth {
+		best, min := 0, inf
+		for i, e := range x {
+			if h := g.exprHeight(e); h < min {
+				best, min = i, h
+			}
+		}
+		return best
+	}
+	weights := make([]int, len(x))
+	total := 0
+	for i, e := range x {
+		weights[i] = len(analysis.FirstExpr(e, g.first, g.nullable))
+		if weights[i] == 0 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+	r := rnd.Intn(total)
+	for i, w := range weights {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	return len(x) - 1
+}
+
+// exprHeight returns the minimum expansion height of the given expression.
+func (g *generator) exprHeight(x ebnf.Expression) int {
+	switch x := x.(type) {
+	case nil, *ebnf.Token, *ebnf.Range, *ebnf.Option, *ebnf.Repetition:
+		return 0
+	case ebnf.Alternative:
+		min := inf
+		for _, e := range x {
+			if h := g.exprHeight(e); h < min {
+				min = h
+			}
+		}
+		return min
+	case ebnf.Sequence:
+		max := 0
+		for _, e := range x {
+			if h := g.exprHeight(e); h > max {
+				max = h
+			}
+		}
+		return max
+	case *ebnf.Name:
+		return g.height[x.String]
+	case *ebnf.Group:
+		return g.exprHeight(x.Body)
+	default:
+		panic(fmt.Errorf("support for expression %T not yet implemented", x))
+	}
+}
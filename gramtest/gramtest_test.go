@@ -0,0 +1,80 @@
+package gramtest
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/ebnf"
+)
+
+func TestCheck(t *testing.T) {
+	const src = `
+List = Item { "," Item } .
+Item = Call | ident | number | "[" [ List ] "]" .
+Call = ident "(" [ List ] ")" .
+ident = letter { letter | digit } .
+number = digit { digit } .
+letter = "a" … "z" .
+digit = "0" … "9" .
+skip = " " | "\n" .
+`
+	Check(t, parseGrammar(t, src), "List", 200)
+}
+
+func TestCheckFailure(t *testing.T) {
+	golden := []struct {
+		src   string
+		start string
+		want  string
+	}{
+		// Call is shadowed by ident, as ordered choice never tries Call.
+		{
+			src: `
+Item = ident | Call .
+Call = ident "(" ")" .
+ident = "a" … "z" { "a" … "z" } .
+skip = " " .
+`,
+			start: "Item",
+			want:  "runtime evaluator rejected input",
+		},
+		// Left-recursive grammar.
+		{
+			src: `
+Expr = Expr "+" number | number .
+number = "0" … "9" .
+`,
+			start: "Expr",
+			want:  "unable to evaluate left-recursive grammar",
+		},
+		// Undefined production.
+		{
+			src: `
+Expr = Term .
+`,
+			start: "Expr",
+			want:  "invalid grammar",
+		},
+	}
+	for _, g := range golden {
+		err := check(parseGrammar(t, g.src), g.start, 100, rand.New(rand.NewSource(1)))
+		if err == nil {
+			t.Errorf("%q: expected error containing %q, got nil", g.src, g.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), g.want) {
+			t.Errorf("%q: error mismatch; expected error containing %q, got %q", g.src, g.want, err.Error())
+		}
+	}
+}
+
+// parseGrammar parses the given EBNF grammar.
+func parseGrammar(t *testing.T, src string) ebnf.Grammar {
+	t.Helper()
+	grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unable to parse grammar; %v", err)
+	}
+	return grammar
+}
@@ -0,0 +1,98 @@
+// Package incr implements incremental re-parsing of edited input, as used by
+// editors to re-parse a document on every change.
+//
+// The results of production rules are cached per production and offset of the
+// input. When the input is edited, only production rules whose examined input
+// overlaps the edited region are evaluated again; the results of production
+// rules before and after the edited region are reused.
+package incr
+
+import (
+	"context"
+
+	"github.com/mewmew/speak/eval"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/ebnf"
+)
+
+// Edit is an edit of the input, replacing a range of bytes.
+type Edit struct {
+	// Byte offset of the edit in the input.
+	Offset int
+	// Number of bytes deleted at the offset.
+	DeleteLen int
+	// Bytes inserted at the offset.
+	Insert []byte
+}
+
+// IncrementalParser parses input by runtime evaluation of a grammar, and
+// re-parses edited input reusing the results of unaffected production rules.
+type IncrementalParser struct {
+	// EBNF grammar.
+	grammar ebnf.Grammar
+	// Start production rule.
+	start string
+	// Runtime evaluator configuration.
+	conf eval.Config
+	// Current input.
+	input []byte
+}
+
+// New returns a new incremental parser of the given grammar, from the given
+// start production rule. The runtime evaluator is configured by conf, with
+// parse trees enabled; tracing, streaming, output maps and error recovery
+// disable reuse of production rule results.
+func New(grammar ebnf.Grammar, start string, conf eval.Config) *IncrementalParser {
+	conf.Tree = true
+	conf.Memo = eval.NewMemo()
+	return &IncrementalParser{
+		grammar: grammar,
+		start:   start,
+		conf:    conf,
+	}
+}
+
+// Parse parses the given input, discarding cached production rule results, and
+// returns the root node of the parse tree. The parse error at the furthest
+// offset reached is returned if the input is invalid.
+func (ip *IncrementalParser) Parse(input []byte) (*eval.ParseNode, error) {
+	ip.conf.Memo = eval.NewMemo()
+	ip.input = input
+	return ip.parse()
+}
+
+// Reparse applies the given edit to the current input and parses the edited
+// input, reusing the cached results of production rules which did not examine
+// the edited region. The root node of the parse tree is returned, or the parse
+// error at the furthest offset reached if the edited input is invalid.
+func (ip *IncrementalParser) Reparse(edit Edit) (*eval.ParseNode, error) {
+	if edit.Offset < 0 || edit.DeleteLen < 0 || edit.Offset+edit.DeleteLen > len(ip.input) {
+		return nil, errors.Errorf("invalid edit of %d bytes at offset %d; input of %d bytes", edit.DeleteLen, edit.Offset, len(ip.input))
+	}
+	// The edited input is a new slice, as the matched source text of reused
+	// parse tree nodes refers to the previous input.
+	input := make([]byte, 0, len(ip.input)-edit.DeleteLen+len(edit.Insert))
+	input = append(input, ip.input[:edit.Offset]...)
+	input = append(input, edit.Insert...)
+	input = append(input, ip.input[edit.Offset+edit.DeleteLen:]...)
+	ip.input = input
+	ip.conf.Memo.Edit(edit.Offset, edit.DeleteLen, len(edit.Insert))
+	return ip.parse()
+}
+
+// Input returns the current input of the incremental parser.
+func (ip *IncrementalParser) Input() []byte {
+	return ip.input
+}
+
+// parse parses the current input.
+func (ip *IncrementalParser) parse() (*eval.ParseNode, error) {
+	root, errs, _, err := eval.Parse(context.Background(), ip.grammar, ip.input, &ip.conf, ip.start)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.WithStack(errs[0])
+	}
+	return root, nil
+}
@@ -0,0 +1,125 @@
+package incr
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/mewmew/speak/analysis"
+	"github.com/mewmew/speak/eval"
+	"golang.org/x/exp/ebnf"
+)
+
+const src = `
+List = Item { "," Item } .
+Item = Call | ident | number | "[" [ List ] "]" .
+Call = ident "(" [ List ] ")" .
+ident = letter { letter | digit } .
+number = digit { digit } .
+letter = "a" … "z" .
+digit = "0" … "9" .
+skip = " " | "\n" .
+`
+
+// testConfig returns the runtime evaluator configuration of the given grammar.
+func testConfig(t *testing.T) (ebnf.Grammar, eval.Config) {
+	t.Helper()
+	grammar, err := ebnf.Parse("test.ebnf", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unable to parse grammar; %v", err)
+	}
+	conf := eval.Config{
+		SkipRule: "skip",
+		Tree:     true,
+		First:    analysis.First(grammar),
+		Nullable: analysis.Nullable(grammar),
+	}
+	return grammar, conf
+}
+
+func TestReparse(t *testing.T) {
+	grammar, conf := testConfig(t)
+	ip := New(grammar, "List", conf)
+	input := "foo, [1, 2], f(x, [y]),\n42, bar"
+	if _, err := ip.Parse([]byte(input)); err != nil {
+		t.Fatalf("unable to parse %q; %v", input, err)
+	}
+	// Apply random edits, and compare the result of each re-parse with that of
+	// a full parse of the edited input.
+	const alphabet = "ab1,,[]() \n"
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		in := ip.Input()
+		off := r.Intn(len(in) + 1)
+		edit := Edit{Offset: off, DeleteLen: r.Intn(min(3, len(in)-off) + 1)}
+		for n := r.Intn(3); n > 0; n-- {
+			edit.Insert = append(edit.Insert, alphabet[r.Intn(len(alphabet))])
+		}
+		got, gotErr := ip.Reparse(edit)
+		want, wantErrs, _, err := eval.Parse(context.Background(), grammar, ip.Input(), &conf, "List")
+		if err != nil {
+			t.Fatalf("unable to parse %q; %+v", ip.Input(), err)
+		}
+		switch {
+		case len(wantErrs) > 0 && gotErr == nil:
+			t.Fatalf("edit %d of %q: expected error %q, got none", i, ip.Input(), wantErrs[0])
+		case len(wantErrs) > 0 && gotErr.Error() != wantErrs[0].Error():
+			t.Fatalf("edit %d of %q: error mismatch; expected %q, got %q", i, ip.Input(), wantErrs[0], gotErr)
+		case len(wantErrs) == 0 && gotErr != nil:
+			t.Fatalf("edit %d of %q: unexpected error; %v", i, ip.Input(), gotErr)
+		case len(wantErrs) == 0:
+			if g, w := treeString(t, got), treeString(t, want); g != w {
+				t.Fatalf("edit %d of %q: parse tree mismatch;\nexpected %s\ngot      %s", i, ip.Input(), w, g)
+			}
+		}
+	}
+}
+
+func TestReparseReuse(t *testing.T) {
+	grammar, conf := testConfig(t)
+	ip := New(grammar, "List", conf)
+	old, err := ip.Parse([]byte("foo, [1, 2], bar"))
+	if err != nil {
+		t.Fatalf("unable to parse input; %v", err)
+	}
+	// Replace "bar" by "f(x)".
+	root, err := ip.Reparse(Edit{Offset: 13, DeleteLen: 3, Insert: []byte("f(x)")})
+	if err != nil {
+		t.Fatalf("unable to re-parse input; %v", err)
+	}
+	if got, want := root.TextString(), "foo, [1, 2], f(x)"; got != want {
+		t.Fatalf("text mismatch; expected %q, got %q", want, got)
+	}
+	// The items before the edit are reused; the edited item is not.
+	for i, reused := range []bool{true, true, false} {
+		if got := root.Children[i] == old.Children[i]; got != reused {
+			t.Errorf("reuse mismatch of item %d (%q); expected %v, got %v", i, root.Children[i].TextString(), reused, got)
+		}
+	}
+	// Insert "x, " before "[1, 2]"; the shifted item is reused with updated
+	// offsets.
+	old = root
+	root, err = ip.Reparse(Edit{Offset: 5, Insert: []byte("x, ")})
+	if err != nil {
+		t.Fatalf("unable to re-parse input; %v", err)
+	}
+	item := root.Children[2]
+	if got, want := item.TextString(), "[1, 2]"; got != want {
+		t.Fatalf("text mismatch of shifted item; expected %q, got %q", want, got)
+	}
+	if item.Start != old.Children[1].Start+3 || len(item.Children) != len(old.Children[1].Children) {
+		t.Errorf("shifted item mismatch; expected start %d, got %d", old.Children[1].Start+3, item.Start)
+	}
+}
+
+// treeString returns the JSON encoding of the given parse tree.
+func treeString(t *testing.T, root *eval.ParseNode) string {
+	t.Helper()
+	buf, err := json.Marshal(root)
+	if err != nil {
+		t.Fatalf("unable to marshal parse tree; %v", err)
+	}
+	return string(buf)
+}
@@ -0,0 +1,367 @@
+// Package suggest implements grammar-based completion of partial input.
+//
+// The input up to the cursor is evaluated in completion mode, where running
+// into the end of input is recorded as a failure point rather than as an
+// error. The suggestions are drawn from the tokens expected at the failure
+// points; tokens partially typed before the cursor, and the FIRST sets of
+// production rules starting at the cursor.
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mewmew/speak/analysis"
+	"golang.org/x/exp/ebnf"
+)
+
+// skipRule is the name of the production rule used to skip whitespace and
+// comments, as by default for the speak tool.
+const skipRule = "skip"
+
+// Suggestion is a completion suggestion.
+type Suggestion struct {
+	// Suggested token.
+	Text string
+	// Production in which the token is expected.
+	Production string
+}
+
+// Suggest returns the completion suggestions at the cursor offset of the given
+// input, as parsed from the start production rule of the grammar. Whitespace
+// and comments are skipped using the production rule named skip, if present.
+//
+// Only tokens of syntactic production rules (e.g. keywords and punctuation)
+// are suggested at the cursor, as the characters expected within lexical
+// production rules (e.g. identifiers) are not meaningful completions.
+//
+// The suggestions are sorted by likelihood; completions of partially typed
+// tokens before tokens expected at the cursor, longer typed prefixes first, and
+// otherwise in order of evaluation.
+//
+// No suggestions are returned for left-recursive grammars, which the
+// evaluator does not support.
+func Suggest(grammar ebnf.Grammar, start string, input []byte, cursor int) []Suggestion {
+	p := eval(grammar, start, input, cursor)
+	if p == nil {
+		return nil
+	}
+	return p.suggestions()
+}
+
+// Productions returns the names of the production rules which may begin at the
+// cursor offset of the given input, as parsed from the start production rule of
+// the grammar, sorted by name. As opposed to Suggest, lexical production rules
+// (e.g. identifiers) are included, to let callers provide completions of their
+// own.
+//
+// No production rules are returned for left-recursive grammars, which the
+// evaluator does not support.
+func Productions(grammar ebnf.Grammar, start string, input []byte, cursor int) []string {
+	p := eval(grammar, start, input, cursor)
+	if p == nil {
+		return nil
+	}
+	m := make(map[string]bool)
+	for _, f := range p.failures {
+		for _, name := range f.starting {
+			m[name] = true
+		}
+	}
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// eval evaluates the input up to the cursor offset in completion mode, from the
+// start production rule of the grammar. A nil parser is returned if the start
+// production rule is not present, the grammar is left-recursive, or the cursor
+// is out of bounds.
+func eval(grammar ebnf.Grammar, start string, input []byte, cursor int) *parser {
+	if _, ok := grammar[start]; !ok {
+		return nil
+	}
+	if len(analysis.DetectLeftRecursion(grammar)) > 0 {
+		return nil
+	}
+	if cursor < 0 || cursor > len(input) {
+		return nil
+	}
+	p := &parser{
+		grammar:  grammar,
+		input:    input[:cursor],
+		literals: literals(grammar),
+	}
+	p.evalProd(grammar[start])
+	return p
+}
+
+// parser is a runtime evaluator of EBNF grammars in completion mode.
+type parser struct {
+	// EBNF language grammar.
+	grammar ebnf.Grammar
+	// Input source up to the cursor.
+	input []byte
+	// Current position in input source.
+	pos int
+	// Currently skipping whitespace and comments.
+	skipping bool
+	// Production rules currently being evaluated, innermost last.
+	stack []frame
+	// Failure points at the end of input, in order of evaluation.
+	failures []failure
+	// FIRST sets of the grammar; computed on demand.
+	first map[string]analysis.Set
+	// Tokens of syntactic production rules.
+	literals map[string]bool
+}
+
+// frame is a production rule being evaluated.
+type frame struct {
+	// Production name.
+	name string
+	// Start offset of the production rule in the input source.
+	start int
+}
+
+// failure is a failure point at the end of input.
+type failure struct {
+	// Expected token; empty for character ranges.
+	token string
+	// Number of bytes of the token typed before the cursor.
+	prefix int
+	// Innermost production rule being evaluated.
+	prod string
+	// Outermost production rule starting at the cursor; empty if none.
+	outer string
+	// Production rules starting at the cursor, outermost first.
+	starting []string
+}
+
+// evalProd evaluates the given production rule.
+func (p *parser) evalProd(x *ebnf.Production) bool {
+	p.skip()
+	p.stack = append(p.stack, frame{name: x.Name.String, start: p.pos})
+	ret := p.evalExpr(x.Expr)
+	p.stack = p.stack[:len(p.stack)-1]
+	return ret
+}
+
+// evalExpr evaluates the given expression, and reports whether it matched the
+// input at the current position.
+func (p *parser) evalExpr(x ebnf.Expression) bool {
+	// skip whitespace and comments in between expressions.
+	p.skip()
+	switch x := x.(type) {
+	case nil:
+		return true
+	case ebnf.Alternative:
+		for _, e := range x {
+			bak := p.pos
+			if p.evalExpr(e) {
+				return true
+			}
+			p.pos = bak
+		}
+		return false
+	case ebnf.Sequence:
+		bak := p.pos
+		for _, e := range x {
+			if !p.evalExpr(e) {
+				p.pos = bak
+				return false
+			}
+		}
+		return true
+	case *ebnf.Name:
+		prod, ok := p.grammar[x.String]
+		if !ok {
+			return false
+		}
+		return p.evalProd(prod)
+	case *ebnf.Token:
+		return p.evalToken(x)
+	case *ebnf.Range:
+		return p.evalRange(x)
+	case *ebnf.Group:
+		return p.evalExpr(x.Body)
+	case *ebnf.Option:
+		// the body is evaluated at the end of input to record failure points.
+		bak := p.pos
+		if !p.evalExpr(x.Body) {
+			p.pos = bak
+		}
+		return true
+	case *ebnf.Repetition:
+		for {
+			bak := p.pos
+			if !p.evalExpr(x.Body) {
+				p.pos = bak
+				break
+			}
+			if p.pos == bak {
+				// body matched empty input; stop to prevent infinite loop.
+				break
+			}
+		}
+		return true
+	default:
+		// *ebnf.Bad
+		return false
+	}
+}
+
+// evalToken evaluates the given token. A token of which a prefix is typed
+// before the end of input is recorded as a failure point.
+func (p *parser) evalToken(x *ebnf.Token) bool {
+	rest := p.input[p.pos:]
+	if strings.HasPrefix(x.String, string(rest)) && len(rest) < len(x.String) {
+		p.fail(x.String, len(rest))
+		return false
+	}
+	if !strings.HasPrefix(string(rest), x.String) {
+		return false
+	}
+	p.pos += len(x.String)
+	return true
+}
+
+// evalRange evaluates the given character range. A range at the end of input
+// is recorded as a failure point.
+func (p *parser) evalRange(x *ebnf.Range) bool {
+	if p.pos >= len(p.input) {
+		p.fail("", 0)
+		return false
+	}
+	from, _ := utf8.DecodeRuneInString(x.Begin.String)
+	to, _ := utf8.DecodeRuneInString(x.End.String)
+	r, size := utf8.DecodeRune(p.input[p.pos:])
+	if r < from || r > to {
+		return false
+	}
+	p.pos += size
+	return true
+}
+
+// skip evaluates the skip production rule to ignore whitespace and comments.
+func (p *parser) skip() {
+	if p.skipping {
+		return
+	}
+	skip, ok := p.grammar[skipRule]
+	if !ok {
+		return
+	}
+	p.skipping = true
+	for p.pos < len(p.input) {
+		bak := p.pos
+		if !p.evalExpr(skip.Expr) || p.pos == bak {
+			p.pos = bak
+			break
+		}
+	}
+	p.skipping = false
+}
+
+// fail records a failure point at the end of input, with the given expected
+// token of which prefix bytes are typed.
+func (p *parser) fail(token string, prefix int) {
+	if p.skipping {
+		return
+	}
+	f := failure{
+		token:  token,
+		prefix: prefix,
+		prod:   p.stack[len(p.stack)-1].name,
+	}
+	if prefix == 0 {
+		for _, fr := range p.stack {
+			if fr.start == p.pos {
+				f.starting = append(f.starting, fr.name)
+			}
+		}
+		if len(f.starting) > 0 {
+			f.outer = f.starting[0]
+		}
+	}
+	p.failures = append(p.failures, f)
+}
+
+// suggestions returns the completion suggestions of the recorded failure
+// points, sorted by likelihood.
+func (p *parser) suggestions() []Suggestion {
+	type scored struct {
+		Suggestion
+		prefix int
+	}
+	var ss []scored
+	seen := make(map[string]bool)
+	add := func(text, prod string, prefix int) {
+		if len(text) == 0 || seen[text] || (prefix == 0 && !p.literals[text]) {
+			return
+		}
+		seen[text] = true
+		ss = append(ss, scored{Suggestion: Suggestion{Text: text, Production: prod}, prefix: prefix})
+	}
+	for _, f := range p.failures {
+		if len(f.outer) == 0 {
+			add(f.token, f.prod, f.prefix)
+			continue
+		}
+		// Suggest the tokens of the FIRST set of the outermost production rule
+		// starting at the cursor.
+		if p.first == nil {
+			p.first = analysis.First(p.grammar)
+		}
+		for _, t := range p.first[f.outer].Sorted() {
+			add(t.Token, f.outer, 0)
+		}
+	}
+	sort.SliceStable(ss, func(i, j int) bool {
+		return ss[i].prefix > ss[j].prefix
+	})
+	var suggestions []Suggestion
+	for _, s := range ss {
+		suggestions = append(suggestions, s.Suggestion)
+	}
+	return suggestions
+}
+
+// ### [ Helper functions ] ####################################################
+
+// literals returns the tokens of the syntactic production rules of the given
+// grammar.
+func literals(grammar ebnf.Grammar) map[string]bool {
+	m := make(map[string]bool)
+	var walk func(x ebnf.Expression)
+	walk = func(x ebnf.Expression) {
+		switch x := x.(type) {
+		case ebnf.Alternative:
+			for _, e := range x {
+				walk(e)
+			}
+		case ebnf.Sequence:
+			for _, e := range x {
+				walk(e)
+			}
+		case *ebnf.Token:
+			m[x.String] = true
+		case *ebnf.Group:
+			walk(x.Body)
+		case *ebnf.Option:
+			walk(x.Body)
+		case *ebnf.Repetition:
+			walk(x.Body)
+		}
+	}
+	for name, prod := range grammar {
+		if !analysis.IsLexical(name) {
+			walk(prod.Expr)
+		}
+	}
+	return m
+}